@@ -0,0 +1,155 @@
+package avroserializer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+)
+
+// Registry stores the schemas records were written with so they can be resolved against the
+// schemas of the current event types when read.  Schemas are identified by their CRC-64-AVRO
+// fingerprint; see Fingerprint
+type Registry interface {
+	// Register stores the schema; registering a schema that is already stored is a no-op
+	Register(schema avro.Schema) error
+
+	// Schema returns the schema with the fingerprint specified
+	Schema(fingerprint uint64) (avro.Schema, error)
+}
+
+// MemoryRegistry provides an in-memory Registry intended for tests
+type MemoryRegistry struct {
+	mux     sync.Mutex
+	schemas map[uint64]avro.Schema
+}
+
+// NewMemoryRegistry returns an empty MemoryRegistry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		schemas: map[uint64]avro.Schema{},
+	}
+}
+
+// Register implements Registry
+func (m *MemoryRegistry) Register(schema avro.Schema) error {
+	fingerprint, err := Fingerprint(schema)
+	if err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.schemas[fingerprint]; !ok {
+		m.schemas[fingerprint] = schema
+	}
+	return nil
+}
+
+// Schema implements Registry
+func (m *MemoryRegistry) Schema(fingerprint uint64) (avro.Schema, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	schema, ok := m.schemas[fingerprint]
+	if !ok {
+		return nil, fmt.Errorf("no schema registered with fingerprint, %016x", fingerprint)
+	}
+	return schema, nil
+}
+
+// FileRegistry provides a Registry that stores each schema as a file, <fingerprint>.avsc, in a
+// directory.  The directory may be checked into source control alongside the events so every
+// schema ever written remains available
+type FileRegistry struct {
+	dir string
+
+	mux     sync.Mutex
+	schemas map[uint64]avro.Schema
+}
+
+// NewFileRegistry returns a FileRegistry that stores schemas in dir, creating dir if required
+func NewFileRegistry(dir string) (*FileRegistry, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create schema directory, %v: %v", dir, err)
+	}
+
+	return &FileRegistry{
+		dir:     dir,
+		schemas: map[uint64]avro.Schema{},
+	}, nil
+}
+
+func (f *FileRegistry) path(fingerprint uint64) string {
+	return filepath.Join(f.dir, fmt.Sprintf("%016x.avsc", fingerprint))
+}
+
+// Register implements Registry.  Schemas are written to a temporary file and renamed so that
+// readers never observe a partially written schema
+func (f *FileRegistry) Register(schema avro.Schema) error {
+	fingerprint, err := Fingerprint(schema)
+	if err != nil {
+		return err
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if _, ok := f.schemas[fingerprint]; ok {
+		return nil
+	}
+
+	path := f.path(fingerprint)
+	if _, err := os.Stat(path); err == nil {
+		f.schemas[fingerprint] = schema
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".schema")
+	if err != nil {
+		return fmt.Errorf("unable to register schema, %016x: %v", fingerprint, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(schema.String()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to register schema, %016x: %v", fingerprint, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to register schema, %016x: %v", fingerprint, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to register schema, %016x: %v", fingerprint, err)
+	}
+
+	f.schemas[fingerprint] = schema
+	return nil
+}
+
+// Schema implements Registry
+func (f *FileRegistry) Schema(fingerprint uint64) (avro.Schema, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if schema, ok := f.schemas[fingerprint]; ok {
+		return schema, nil
+	}
+
+	data, err := os.ReadFile(f.path(fingerprint))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema, %016x: %v", fingerprint, err)
+	}
+
+	// parse with a private cache so named types from different revisions of an event do not
+	// replace one another
+	schema, err := avro.ParseBytesWithCache(data, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse schema, %016x: %v", fingerprint, err)
+	}
+
+	f.schemas[fingerprint] = schema
+	return schema, nil
+}
@@ -0,0 +1,167 @@
+package avroserializer
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/hamba/avro/v2"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaBuilder derives avro schemas from Go types.  Every field is given the zero value of its
+// type as a default so that fields may be added to events without breaking old records
+type schemaBuilder struct {
+	namespace string
+	visiting  map[reflect.Type]bool
+}
+
+// recordSchema derives the record schema, named name, for the struct type t.  Fields of
+// embedded structs, such as eventsource.Model, are promoted into the record.  Field names may be
+// overridden with the avro struct tag; fields tagged avro:"-" are skipped.
+//
+// Records nested within the record are named after the record and the field that contains
+// them, <name>_<field>, rather than their Go type.  Nested names are therefore unique within
+// the schema, even when the same type, or two types of the same name, appear more than once, and
+// do not change when a type is renamed or moved to another package
+func (b *schemaBuilder) recordSchema(name string, t reflect.Type) (*avro.RecordSchema, error) {
+	if b.visiting[t] {
+		return nil, fmt.Errorf("recursive type, %v, is not supported", t)
+	}
+	b.visiting[t] = true
+	defer delete(b.visiting, t)
+
+	var fields []*avro.Field
+	if err := b.fields(name, t, map[string]bool{}, &fields); err != nil {
+		return nil, err
+	}
+
+	schema, err := avro.NewRecordSchema(name, b.namespace, fields)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create schema for %v: %v", t, err)
+	}
+	return schema, nil
+}
+
+// fields appends the fields of the struct t, contained by the record named parent, followed by
+// the fields promoted from its embedded structs; as with Go, fields of the outer struct take
+// precedence
+func (b *schemaBuilder) fields(parent string, t reflect.Type, seen map[string]bool, fields *[]*avro.Field) error {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Anonymous {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("avro"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		schema, def, err := b.schema(parent+"_"+name, field.Type)
+		if err != nil {
+			return fmt.Errorf("unable to derive schema for field, %v.%v: %v", t, field.Name, err)
+		}
+
+		f, err := avro.NewField(name, schema, avro.WithDefault(def))
+		if err != nil {
+			return fmt.Errorf("unable to derive schema for field, %v.%v: %v", t, field.Name, err)
+		}
+		*fields = append(*fields, f)
+	}
+
+	for _, ft := range embedded {
+		if err := b.fields(parent, ft, seen, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schema returns the schema for t along with its default value; name is the name given to t,
+// or to the element type of t, should it be a struct
+func (b *schemaBuilder) schema(name string, t reflect.Type) (avro.Schema, interface{}, error) {
+	if t == timeType {
+		return avro.NewPrimitiveSchema(avro.Long, avro.NewPrimitiveLogicalSchema(avro.TimestampMicros)), int64(0), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return avro.NewPrimitiveSchema(avro.Boolean, nil), false, nil
+
+	case reflect.String:
+		return avro.NewPrimitiveSchema(avro.String, nil), "", nil
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return avro.NewPrimitiveSchema(avro.Int, nil), 0, nil
+
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return avro.NewPrimitiveSchema(avro.Long, nil), int64(0), nil
+
+	case reflect.Float32:
+		return avro.NewPrimitiveSchema(avro.Float, nil), float32(0), nil
+
+	case reflect.Float64:
+		return avro.NewPrimitiveSchema(avro.Double, nil), float64(0), nil
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return avro.NewPrimitiveSchema(avro.Bytes, nil), "", nil
+		}
+		items, _, err := b.schema(name, t.Elem())
+		if err != nil {
+			return nil, nil, err
+		}
+		return avro.NewArraySchema(items), []interface{}{}, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, nil, fmt.Errorf("map keys must be strings, got %v", t.Key())
+		}
+		values, _, err := b.schema(name, t.Elem())
+		if err != nil {
+			return nil, nil, err
+		}
+		return avro.NewMapSchema(values), map[string]interface{}{}, nil
+
+	case reflect.Ptr:
+		elem, _, err := b.schema(name, t.Elem())
+		if err != nil {
+			return nil, nil, err
+		}
+		union, err := avro.NewUnionSchema([]avro.Schema{avro.NewPrimitiveSchema(avro.Null, nil), elem})
+		if err != nil {
+			return nil, nil, err
+		}
+		return union, nil, nil
+
+	case reflect.Struct:
+		record, err := b.recordSchema(name, t)
+		if err != nil {
+			return nil, nil, err
+		}
+		return record, map[string]interface{}{}, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported type, %v", t)
+}
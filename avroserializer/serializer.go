@@ -0,0 +1,230 @@
+// Package avroserializer provides an eventsource.Serializer that encodes events with Avro.
+//
+// Schemas are derived from the bound Go event structs; each event type becomes a record named
+// after eventsource.EventType and each struct nested within it a record named after the field
+// that contains it, <event type>_<field>.  Records use the Avro single object encoding: a two byte marker,
+// the CRC-64-AVRO fingerprint of the schema the event was written with, then the Avro binary
+// encoded event.  Every schema written is stored in a Registry so that, when a record is read,
+// its writer schema can be resolved against the schema of the current Go struct.  Fields may be
+// added to or removed from events; added fields take the zero value when old records are read.
+package avroserializer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/hamba/avro/v2"
+)
+
+// header is the marker that begins each record in the Avro single object encoding
+var header = [2]byte{0xC3, 0x01}
+
+// headerSize is the size of the marker plus the fingerprint
+const headerSize = 10
+
+// Fingerprint returns the CRC-64-AVRO fingerprint of the schema's parsing canonical form
+func Fingerprint(schema avro.Schema) (uint64, error) {
+	fingerprint, err := schema.FingerprintUsing(avro.CRC64Avro)
+	if err != nil {
+		return 0, fmt.Errorf("unable to fingerprint schema: %v", err)
+	}
+	return binary.BigEndian.Uint64(fingerprint), nil
+}
+
+// Option provides functional configuration for a *Serializer
+type Option func(*Serializer)
+
+// WithNamespace specifies the namespace of the derived record schemas; defaults to none
+func WithNamespace(namespace string) Option {
+	return func(s *Serializer) {
+		s.namespace = namespace
+	}
+}
+
+// binding associates an event type with its derived schema
+type binding struct {
+	t           reflect.Type
+	schema      *avro.RecordSchema
+	fingerprint uint64
+}
+
+type resolveKey struct {
+	reader, writer uint64
+}
+
+// Serializer converts between events and Avro encoded Records
+type Serializer struct {
+	registry  Registry
+	namespace string
+
+	mux      sync.Mutex
+	byName   map[string]*binding
+	byType   map[reflect.Type]*binding
+	resolved map[resolveKey]avro.Schema
+}
+
+// New returns a Serializer that stores schemas in registry and binds the events provided
+func New(registry Registry, events []eventsource.Event, opts ...Option) (*Serializer, error) {
+	s := &Serializer{
+		registry: registry,
+		byName:   map[string]*binding{},
+		byType:   map[reflect.Type]*binding{},
+		resolved: map[resolveKey]avro.Schema{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.Bind(events...); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Bind derives the schema of each event and registers it with the Registry; may be called more
+// than once
+func (s *Serializer) Bind(events ...eventsource.Event) error {
+	for _, event := range events {
+		if _, err := s.bind(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bind returns the binding for the event, deriving and registering its schema if required
+func (s *Serializer) bind(event eventsource.Event) (*binding, error) {
+	eventType, t := eventsource.EventType(event)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if b, ok := s.byType[t]; ok {
+		return b, nil
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable to bind %T: events must be structs", event)
+	}
+
+	builder := &schemaBuilder{namespace: s.namespace, visiting: map[reflect.Type]bool{}}
+	schema, err := builder.recordSchema(eventType, t)
+	if err != nil {
+		return nil, fmt.Errorf("unable to bind %T: %v", event, err)
+	}
+
+	fingerprint, err := Fingerprint(schema)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.registry.Register(schema); err != nil {
+		return nil, fmt.Errorf("unable to bind %T: %v", event, err)
+	}
+
+	b := &binding{t: t, schema: schema, fingerprint: fingerprint}
+	s.byType[t] = b
+	s.byName[schema.FullName()] = b
+
+	return b, nil
+}
+
+// MarshalEvent implements eventsource.Serializer.  Events that have not been bound are bound
+// on first use
+func (s *Serializer) MarshalEvent(event eventsource.Event) (eventsource.Record, error) {
+	b, err := s.bind(event)
+	if err != nil {
+		return eventsource.Record{}, err
+	}
+
+	payload, err := avro.Marshal(b.schema, event)
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to marshal event, %v: %v", b.schema.FullName(), err)
+	}
+
+	data := make([]byte, headerSize, headerSize+len(payload))
+	copy(data, header[:])
+	binary.LittleEndian.PutUint64(data[2:headerSize], b.fingerprint)
+	data = append(data, payload...)
+
+	return eventsource.Record{
+		Version: event.EventVersion(),
+		Data:    data,
+	}, nil
+}
+
+// UnmarshalEvent implements eventsource.Serializer.  The schema the record was written with is
+// read from the Registry and resolved against the schema of the bound event.  Returns an error
+// detectable with eventsource.IsUnboundEventTypeError if no event with the same name is bound
+func (s *Serializer) UnmarshalEvent(record eventsource.Record) (eventsource.Event, error) {
+	data := record.Data
+	if len(data) < headerSize || data[0] != header[0] || data[1] != header[1] {
+		return nil, fmt.Errorf("record at version, %v, is not avro single object encoded", record.Version)
+	}
+	fingerprint := binary.LittleEndian.Uint64(data[2:headerSize])
+
+	writer, err := s.registry.Schema(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	named, ok := writer.(avro.NamedSchema)
+	if !ok {
+		return nil, fmt.Errorf("schema, %016x, is not a record", fingerprint)
+	}
+
+	b, schema, err := s.reader(named, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	v := reflect.New(b.t).Interface()
+	if err := avro.Unmarshal(schema, data[headerSize:], v); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal event, %v: %v", named.FullName(), err)
+	}
+
+	return v.(eventsource.Event), nil
+}
+
+// reader returns the binding for the writer schema along with the schema to decode with; the
+// bound schema itself or the resolution of the writer schema against it
+func (s *Serializer) reader(writer avro.NamedSchema, fingerprint uint64) (*binding, avro.Schema, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	b, ok := s.byName[writer.FullName()]
+	if !ok {
+		return nil, nil, eventsource.NewUnboundEventTypeError(writer.FullName())
+	}
+	if b.fingerprint == fingerprint {
+		return b, b.schema, nil
+	}
+
+	key := resolveKey{reader: b.fingerprint, writer: fingerprint}
+	if schema, ok := s.resolved[key]; ok {
+		return b, schema, nil
+	}
+
+	schema, err := avro.NewSchemaCompatibility().Resolve(b.schema, writer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to resolve schema, %016x, of event, %v: %v", fingerprint, writer.FullName(), err)
+	}
+	s.resolved[key] = schema
+
+	return b, schema, nil
+}
+
+// MarshalAll is a utility that marshals all the events provided into a History object
+func (s *Serializer) MarshalAll(events ...eventsource.Event) (eventsource.History, error) {
+	history := make(eventsource.History, 0, len(events))
+	for _, event := range events {
+		record, err := s.MarshalEvent(event)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, record)
+	}
+	return history, nil
+}
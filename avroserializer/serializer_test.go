@@ -0,0 +1,237 @@
+package avroserializer_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/avroserializer"
+)
+
+type Address struct {
+	Street string
+	City   string
+}
+
+type OrderPlaced struct {
+	eventsource.Model
+	Amount   int64
+	Items    []string
+	Tags     map[string]string
+	Shipping *Address
+	Billing  Address
+	Note     string `avro:"note"`
+	Internal string `avro:"-"`
+}
+
+// UserRegisteredV1 and UserRegisteredV2 are two revisions of the same event
+type UserRegisteredV1 struct {
+	eventsource.Model
+	Name     string
+	Nickname string
+}
+
+func (UserRegisteredV1) EventType() string { return "UserRegistered" }
+
+type UserRegisteredV2 struct {
+	eventsource.Model
+	Name    string
+	Country string
+}
+
+func (UserRegisteredV2) EventType() string { return "UserRegistered" }
+
+func newSerializer(t *testing.T, registry avroserializer.Registry, events ...eventsource.Event) *avroserializer.Serializer {
+	serializer, err := avroserializer.New(registry, events, avroserializer.WithNamespace("eventsource.test"))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	return serializer
+}
+
+func TestSerializer(t *testing.T) {
+	serializer := newSerializer(t, avroserializer.NewMemoryRegistry(), OrderPlaced{})
+
+	event := OrderPlaced{
+		Model:    eventsource.Model{ID: "abc", Version: 1, At: time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)},
+		Amount:   100,
+		Items:    []string{"a", "b"},
+		Tags:     map[string]string{"k": "v"},
+		Shipping: &Address{Street: "Queen St", City: "Auckland"},
+		Billing:  Address{City: "Wellington"},
+		Note:     "note",
+		Internal: "not serialized",
+	}
+
+	record, err := serializer.MarshalEvent(event)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := record.Version, 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	v, err := serializer.UnmarshalEvent(record)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := event
+	want.Internal = ""
+	got := *v.(*OrderPlaced)
+	if !got.At.Equal(want.At) {
+		t.Fatalf("got %v; want %v", got.At, want.At)
+	}
+	got.At = want.At
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v; want %#v", got, want)
+	}
+}
+
+func TestSerializer_Evolution(t *testing.T) {
+	dir, err := os.MkdirTemp("", "avroserializer")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	defer os.RemoveAll(dir)
+
+	registry, err := avroserializer.NewFileRegistry(dir)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	v1 := newSerializer(t, registry, UserRegisteredV1{})
+	record, err := v1.MarshalEvent(UserRegisteredV1{
+		Model:    eventsource.Model{ID: "abc", Version: 1},
+		Name:     "alice",
+		Nickname: "al",
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	schemas, _ := filepath.Glob(filepath.Join(dir, "*.avsc"))
+	if got, want := len(schemas), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// a new process with the next revision of the event reads the writer schema from disk
+	registry, err = avroserializer.NewFileRegistry(dir)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	v2 := newSerializer(t, registry, UserRegisteredV2{})
+
+	v, err := v2.UnmarshalEvent(record)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	event := v.(*UserRegisteredV2)
+	if got, want := event.Name, "alice"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := event.Country, ""; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := event.AggregateID(), "abc"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+// PostalAddress refers to Address within functions that declare a type of the same name
+type PostalAddress = Address
+
+func TestSerializer_NestedRecords(t *testing.T) {
+	// Address shares its name with the package level Address
+	type Address struct {
+		Line string
+	}
+	type Delivery struct {
+		eventsource.Model
+		Shipping *PostalAddress
+		Billing  PostalAddress
+		Depot    Address
+		Window   struct {
+			From time.Time
+			To   time.Time
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "avroserializer")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	defer os.RemoveAll(dir)
+
+	registry, err := avroserializer.NewFileRegistry(dir)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	serializer := newSerializer(t, registry, Delivery{})
+
+	event := Delivery{
+		Model:    eventsource.Model{ID: "abc", Version: 1},
+		Shipping: &PostalAddress{Street: "Queen St", City: "Auckland"},
+		Billing:  PostalAddress{Street: "Lambton Quay", City: "Wellington"},
+		Depot:    Address{Line: "Depot Rd"},
+	}
+	event.Window.From = time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	event.Window.To = event.Window.From.Add(time.Hour)
+
+	record, err := serializer.MarshalEvent(event)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// the schema read back from disk parses even though Address appears more than once
+	registry, err = avroserializer.NewFileRegistry(dir)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if _, err := registry.Schema(binary.LittleEndian.Uint64(record.Data[2:10])); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	v, err := serializer.UnmarshalEvent(record)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	got := *v.(*Delivery)
+	if !got.Window.From.Equal(event.Window.From) || !got.Window.To.Equal(event.Window.To) {
+		t.Fatalf("got %v; want %v", got.Window, event.Window)
+	}
+	got.At, got.Window = event.At, event.Window
+	if !reflect.DeepEqual(got, event) {
+		t.Fatalf("got %#v; want %#v", got, event)
+	}
+}
+
+func TestSerializer_Errors(t *testing.T) {
+	registry := avroserializer.NewMemoryRegistry()
+	serializer := newSerializer(t, registry, OrderPlaced{})
+
+	record, err := serializer.MarshalEvent(UserRegisteredV1{Model: eventsource.Model{ID: "abc", Version: 1}})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	other := newSerializer(t, registry, OrderPlaced{})
+	if _, err := other.UnmarshalEvent(record); !eventsource.IsUnboundEventTypeError(err) {
+		t.Fatalf("got %v; want error detectable with IsUnboundEventTypeError", err)
+	}
+
+	if _, err := serializer.UnmarshalEvent(eventsource.Record{Data: []byte("{}")}); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+
+	type Unsupported struct {
+		eventsource.Model
+		Channel chan int
+	}
+	if err := serializer.Bind(Unsupported{}); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
@@ -0,0 +1,14 @@
+module github.com/eventsource-ecosystem/eventsource/cborserializer
+
+go 1.21
+
+require github.com/eventsource-ecosystem/eventsource v0.0.0-00010101000000-000000000000
+
+require github.com/x448/float16 v0.8.4 // indirect
+
+require (
+	github.com/fxamacker/cbor/v2 v2.9.0
+	golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7 // indirect
+)
+
+replace github.com/eventsource-ecosystem/eventsource => ..
@@ -0,0 +1,67 @@
+// Package cborserializer provides an eventsource.Serializer that encodes events as CBOR, a
+// compact binary alternative to eventsource.JSONSerializer.
+//
+// Each record contains a two element CBOR array holding the event type and the encoded event.
+// Times are encoded as RFC 3339 strings with nanosecond precision so they survive a round trip.
+package cborserializer
+
+import (
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/internal/codecserializer"
+	"github.com/fxamacker/cbor/v2"
+)
+
+var (
+	encMode = mustEncMode(cbor.EncOptions{Time: cbor.TimeRFC3339Nano})
+	decMode = mustDecMode(cbor.DecOptions{})
+)
+
+func mustEncMode(opts cbor.EncOptions) cbor.EncMode {
+	mode, err := opts.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}
+
+func mustDecMode(opts cbor.DecOptions) cbor.DecMode {
+	mode, err := opts.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}
+
+// Serializer converts between Events and CBOR encoded Records
+type Serializer struct {
+	codec *codecserializer.Serializer
+}
+
+// Bind registers the specified events with the serializer; may be called more than once
+func (s *Serializer) Bind(events ...eventsource.Event) {
+	s.codec.Bind(events...)
+}
+
+// MarshalEvent implements eventsource.Serializer
+func (s *Serializer) MarshalEvent(event eventsource.Event) (eventsource.Record, error) {
+	return s.codec.MarshalEvent(event)
+}
+
+// UnmarshalEvent implements eventsource.Serializer.  Returns an error detectable with
+// eventsource.IsUnboundEventTypeError if the event type has not been bound
+func (s *Serializer) UnmarshalEvent(record eventsource.Record) (eventsource.Event, error) {
+	return s.codec.UnmarshalEvent(record)
+}
+
+// MarshalAll is a utility that marshals all the events provided into a History object
+func (s *Serializer) MarshalAll(events ...eventsource.Event) (eventsource.History, error) {
+	return s.codec.MarshalAll(events...)
+}
+
+// New constructs a new Serializer and populates it with the specified events.  Bind may be
+// subsequently called to add more events
+func New(events ...eventsource.Event) *Serializer {
+	return &Serializer{
+		codec: codecserializer.New(encMode.Marshal, decMode.Unmarshal, events...),
+	}
+}
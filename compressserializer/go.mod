@@ -0,0 +1,12 @@
+module github.com/eventsource-ecosystem/eventsource/compressserializer
+
+go 1.22
+
+require github.com/eventsource-ecosystem/eventsource v0.0.0-00010101000000-000000000000
+
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7 // indirect
+)
+
+replace github.com/eventsource-ecosystem/eventsource => ..
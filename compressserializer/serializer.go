@@ -0,0 +1,235 @@
+// Package compressserializer provides an eventsource.Serializer that compresses the records
+// written by another Serializer with gzip or zstd.
+//
+// Each record begins with a single byte identifying the algorithm its data was compressed with,
+// so records remain readable after the algorithm is changed.  Records smaller than the minimum
+// size are stored uncompressed.  Records written before the Serializer was introduced carry no
+// algorithm byte; see eventsource.FormatSerializer to read them alongside compressed records.
+package compressserializer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Algorithm identifies the compression algorithm applied to a record
+type Algorithm byte
+
+const (
+	// None indicates the record data is stored uncompressed
+	None Algorithm = iota
+
+	// Gzip compresses record data with gzip
+	Gzip
+
+	// Zstd compresses record data with zstd
+	Zstd
+)
+
+// String implements fmt.Stringer
+func (a Algorithm) String() string {
+	switch a {
+	case None:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", byte(a))
+	}
+}
+
+// defaultMaxSize is the default limit on the size of a decompressed record
+const defaultMaxSize = 64 << 20
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdErr     error
+)
+
+// initZstd lazily constructs the shared zstd encoder; safe for concurrent use with EncodeAll
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+	})
+	return zstdErr
+}
+
+// Option provides functional configuration for a *Serializer
+type Option func(*Serializer)
+
+// WithAlgorithm specifies the algorithm new records are compressed with; defaults to Gzip
+func WithAlgorithm(algorithm Algorithm) Option {
+	return func(s *Serializer) {
+		s.algorithm = algorithm
+	}
+}
+
+// WithMinSize specifies the size, in bytes, below which records are stored uncompressed;
+// defaults to 0, compress every record
+func WithMinSize(size int) Option {
+	return func(s *Serializer) {
+		s.minSize = size
+	}
+}
+
+// WithMaxSize specifies the size, in bytes, that a record may not exceed once decompressed;
+// defaults to 64MiB.  Guards against corrupt or hostile records exhausting memory on load
+func WithMaxSize(size int) Option {
+	return func(s *Serializer) {
+		if size > 0 {
+			s.maxSize = size
+		}
+	}
+}
+
+// Serializer compresses the records written by another Serializer
+type Serializer struct {
+	serializer eventsource.Serializer
+	algorithm  Algorithm
+	minSize    int
+	maxSize    int
+
+	decoderOnce sync.Once
+	decoder     *zstd.Decoder
+	decoderErr  error
+}
+
+// New returns a Serializer that compresses the records written by serializer
+func New(serializer eventsource.Serializer, opts ...Option) *Serializer {
+	s := &Serializer{
+		serializer: serializer,
+		algorithm:  Gzip,
+		maxSize:    defaultMaxSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// MarshalEvent implements eventsource.Serializer
+func (s *Serializer) MarshalEvent(event eventsource.Event) (eventsource.Record, error) {
+	record, err := s.serializer.MarshalEvent(event)
+	if err != nil {
+		return eventsource.Record{}, err
+	}
+
+	algorithm := s.algorithm
+	if len(record.Data) < s.minSize {
+		algorithm = None
+	}
+
+	data, err := compress(algorithm, record.Data)
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to compress record at version, %v, with %v: %v", record.Version, algorithm, err)
+	}
+	record.Data = data
+
+	return record, nil
+}
+
+// UnmarshalEvent implements eventsource.Serializer
+func (s *Serializer) UnmarshalEvent(record eventsource.Record) (eventsource.Event, error) {
+	record, err := s.decompress(record)
+	if err != nil {
+		return nil, err
+	}
+	return s.serializer.UnmarshalEvent(record)
+}
+
+// UnmarshalEvents implements eventsource.EventsUnmarshaler
+func (s *Serializer) UnmarshalEvents(record eventsource.Record) ([]eventsource.Event, error) {
+	record, err := s.decompress(record)
+	if err != nil {
+		return nil, err
+	}
+	return eventsource.UnmarshalEvents(s.serializer, record)
+}
+
+// compress returns data compressed with algorithm, prefixed with the algorithm
+func compress(algorithm Algorithm, data []byte) ([]byte, error) {
+	switch algorithm {
+	case None:
+		return append([]byte{byte(None)}, data...), nil
+
+	case Gzip:
+		buf := bytes.NewBuffer([]byte{byte(Gzip)})
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+
+	case Zstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdEncoder.EncodeAll(data, []byte{byte(Zstd)}), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm")
+	}
+}
+
+// zstdDecoder lazily constructs the zstd decoder, limited to maxSize; safe for concurrent use
+// with DecodeAll
+func (s *Serializer) zstdDecoder() (*zstd.Decoder, error) {
+	s.decoderOnce.Do(func() {
+		s.decoder, s.decoderErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(s.maxSize)))
+	})
+	return s.decoder, s.decoderErr
+}
+
+// decompress returns the record with its data decompressed
+func (s *Serializer) decompress(record eventsource.Record) (eventsource.Record, error) {
+	if len(record.Data) == 0 {
+		return eventsource.Record{}, fmt.Errorf("unable to decompress record at version, %v: record is empty", record.Version)
+	}
+
+	algorithm, data := Algorithm(record.Data[0]), record.Data[1:]
+	switch algorithm {
+	case None:
+
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return eventsource.Record{}, fmt.Errorf("unable to decompress record at version, %v, with %v: %v", record.Version, algorithm, err)
+		}
+		data, err = io.ReadAll(io.LimitReader(r, int64(s.maxSize)+1))
+		if err != nil {
+			return eventsource.Record{}, fmt.Errorf("unable to decompress record at version, %v, with %v: %v", record.Version, algorithm, err)
+		}
+		if len(data) > s.maxSize {
+			return eventsource.Record{}, fmt.Errorf("unable to decompress record at version, %v, with %v: exceeds %v bytes", record.Version, algorithm, s.maxSize)
+		}
+
+	case Zstd:
+		decoder, err := s.zstdDecoder()
+		if err != nil {
+			return eventsource.Record{}, err
+		}
+		decoded, err := decoder.DecodeAll(data, nil)
+		if err != nil {
+			return eventsource.Record{}, fmt.Errorf("unable to decompress record at version, %v, with %v: %v", record.Version, algorithm, err)
+		}
+		data = decoded
+
+	default:
+		return eventsource.Record{}, fmt.Errorf("unable to decompress record at version, %v: unknown algorithm, %v", record.Version, algorithm)
+	}
+
+	record.Data = data
+	return record, nil
+}
@@ -0,0 +1,247 @@
+package compressserializer_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/compressserializer"
+)
+
+type Document struct {
+	ID   string
+	Body string
+}
+
+type DocumentWritten struct {
+	eventsource.Model
+	Body string
+}
+
+func (d *Document) On(event eventsource.Event) error {
+	switch v := event.(type) {
+	case *DocumentWritten:
+		d.ID = v.ID
+		d.Body += v.Body
+	default:
+		return fmt.Errorf("unhandled event, %T", event)
+	}
+	return nil
+}
+
+func TestSerializer(t *testing.T) {
+	body := strings.Repeat("lorem ipsum ", 100)
+
+	for _, algorithm := range []compressserializer.Algorithm{compressserializer.None, compressserializer.Gzip, compressserializer.Zstd} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			ctx := context.Background()
+			store := eventsource.NewMemoryStore()
+			serializer := compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}),
+				compressserializer.WithAlgorithm(algorithm),
+			)
+			repo := eventsource.New(&Document{},
+				eventsource.WithStore(store),
+				eventsource.WithSerializer(serializer),
+			)
+
+			err := repo.Save(eventsource.ContextWithActor(ctx, "alice"), &DocumentWritten{
+				Model: eventsource.Model{ID: "abc", Version: 1},
+				Body:  body,
+			})
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+
+			v, _, err := repo.Load(ctx, "abc")
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got, want := v.(*Document).Body, body; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+
+			history, err := store.Load(ctx, "abc", 0, 0)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got, want := compressserializer.Algorithm(history[0].Data[0]), algorithm; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := history[0].Metadata[eventsource.MetadataActor], "alice"; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if algorithm != compressserializer.None {
+				if got, want := len(history[0].Data), len(body); got >= want {
+					t.Fatalf("got %v; want less than %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSerializer_MinSize(t *testing.T) {
+	serializer := compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}),
+		compressserializer.WithAlgorithm(compressserializer.Zstd),
+		compressserializer.WithMinSize(256),
+	)
+
+	small, err := serializer.MarshalEvent(&DocumentWritten{Model: eventsource.Model{ID: "abc", Version: 1}})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := compressserializer.Algorithm(small.Data[0]), compressserializer.None; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	large, err := serializer.MarshalEvent(&DocumentWritten{Model: eventsource.Model{ID: "abc", Version: 2}, Body: strings.Repeat("a", 256)})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := compressserializer.Algorithm(large.Data[0]), compressserializer.Zstd; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// records remain readable after the algorithm changes
+	reader := compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}))
+	for _, record := range []eventsource.Record{small, large} {
+		if _, err := reader.UnmarshalEvent(record); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+}
+
+func TestSerializer_MaxSize(t *testing.T) {
+	event := &DocumentWritten{Model: eventsource.Model{ID: "abc", Version: 1}, Body: strings.Repeat("a", 4096)}
+
+	for _, algorithm := range []compressserializer.Algorithm{compressserializer.Gzip, compressserializer.Zstd} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			writer := compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}),
+				compressserializer.WithAlgorithm(algorithm),
+			)
+			record, err := writer.MarshalEvent(event)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+
+			reader := compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}),
+				compressserializer.WithMaxSize(1024),
+			)
+			if _, err := reader.UnmarshalEvent(record); err == nil {
+				t.Fatalf("got nil; want not nil")
+			}
+
+			reader = compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}),
+				compressserializer.WithMaxSize(8192),
+			)
+			if _, err := reader.UnmarshalEvent(record); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+		})
+	}
+}
+
+func TestSerializer_UnmarshalEvents(t *testing.T) {
+	inner := eventsource.NewJSONSerializer(DocumentWritten{})
+	inner.Upcast("DocumentWritten", 0, func(event eventsource.UpcastEvent) ([]eventsource.UpcastEvent, error) {
+		var data map[string]interface{}
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return nil, err
+		}
+		data["Body"] = "upcast"
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		event.Data = encoded
+		return []eventsource.UpcastEvent{event, event}, nil
+	})
+	serializer := compressserializer.New(inner)
+
+	writer := compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}))
+	record, err := writer.MarshalEvent(&DocumentWritten{Model: eventsource.Model{ID: "abc", Version: 1}})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	events, err := eventsource.UnmarshalEvents(serializer, record)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(events), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := events[1].(*DocumentWritten).Body, "upcast"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSerializer_Errors(t *testing.T) {
+	serializer := compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}))
+
+	for _, data := range [][]byte{nil, {0xff}, {byte(compressserializer.Gzip), 1, 2, 3}, {byte(compressserializer.Zstd), 1, 2, 3}} {
+		if _, err := serializer.UnmarshalEvent(eventsource.Record{Data: data}); err == nil {
+			t.Fatalf("got nil; want not nil")
+		}
+	}
+
+	invalid := compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}),
+		compressserializer.WithAlgorithm(compressserializer.Algorithm(0xff)),
+	)
+	if _, err := invalid.MarshalEvent(&DocumentWritten{}); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
+
+func TestSerializer_FormatSerializer(t *testing.T) {
+	// records written before the migration are compressed only when large, so small records
+	// begin with the zero byte compressserializer uses to mark uncompressed data
+	compressed := compressserializer.New(eventsource.NewJSONSerializer(DocumentWritten{}),
+		compressserializer.WithMinSize(1024),
+	)
+	serializer := eventsource.NewFormatSerializer("json", eventsource.NewJSONSerializer(DocumentWritten{}))
+	serializer.Register("compressed", compressed)
+	serializer.Untagged("compressed")
+
+	var history eventsource.History
+	for _, event := range []eventsource.Event{
+		&DocumentWritten{Model: eventsource.Model{ID: "abc", Version: 1}},
+		&DocumentWritten{Model: eventsource.Model{ID: "abc", Version: 2}, Body: strings.Repeat("a", 256)},
+		&DocumentWritten{Model: eventsource.Model{ID: "abc", Version: 3}, Body: strings.Repeat("a", 2048)},
+	} {
+		record, err := compressed.MarshalEvent(event)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		history = append(history, record)
+	}
+	for i, want := range []compressserializer.Algorithm{compressserializer.None, compressserializer.None, compressserializer.Gzip} {
+		if got := compressserializer.Algorithm(history[i].Data[0]); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+
+	tagged, err := serializer.MarshalEvent(&DocumentWritten{Model: eventsource.Model{ID: "abc", Version: 4}, Body: "Jones"})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	for i, record := range append(history, tagged) {
+		if got, want := record.Version, i+1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if _, ok := serializer.Format(record); ok != (i == 3) {
+			t.Fatalf("got %v; want %v", ok, i == 3)
+		}
+
+		event, err := serializer.UnmarshalEvent(record)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := event.EventVersion(), i+1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+module github.com/eventsource-ecosystem/eventsource/dynamodbstore
+
+go 1.23
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.41.0
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.53.5
+	github.com/eventsource-ecosystem/eventsource v0.0.0-00010101000000-000000000000
+	golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7
+)
+
+require (
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.4.16 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.7.16 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.13.4 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/endpoint-discovery v1.11.16 // indirect
+	github.com/aws/smithy-go v1.24.0 // indirect
+)
+
+replace github.com/eventsource-ecosystem/eventsource => ..
@@ -0,0 +1,497 @@
+// Package dynamodbstore provides an eventsource.Store backed by DynamoDB.
+//
+// Records are batched into items keyed by aggregate id and partition, where the partition is
+// the record version divided by the number of events per item.  Each record is stored in an
+// attribute named after its version, _<version>, and is written with a condition that the
+// attribute does not already exist so two writers can never save the same version.  Record
+// metadata, when present, is stored alongside in a map attribute named _m<version>.
+//
+// Aggregate ids beginning with $ are reserved for internal use.
+package dynamodbstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/eventsource-ecosystem/eventsource"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// hashKey is the name of the hash key attribute; contains the aggregate id
+	hashKey = "key"
+
+	// rangeKey is the name of the range key attribute; contains the partition
+	rangeKey = "part"
+
+	// recordPrefix prefixes the attribute name of each record
+	recordPrefix = "_"
+
+	// metadataPrefix prefixes the attribute name of the metadata of each record
+	metadataPrefix = "_m"
+
+	defaultEventsPerItem = 100
+
+	defaultGapTimeout = 10 * time.Second
+
+	// maxTransactItems is the maximum number of actions DynamoDB allows in a transaction
+	maxTransactItems = 100
+)
+
+// DynamoDBAPI contains the subset of *dynamodb.Client used by Store
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// Option provides functional configuration for a *Store
+type Option func(*Store)
+
+// WithEventsPerItem specifies how many records are batched into each item; defaults to 100.
+// Items are limited by DynamoDB to 400KB so eventsPerItem should reflect the size of the events.
+// Changing eventsPerItem for an existing table will make its records unreadable
+func WithEventsPerItem(eventsPerItem int) Option {
+	return func(s *Store) {
+		if eventsPerItem > 0 {
+			s.eventsPerItem = eventsPerItem
+		}
+	}
+}
+
+// WithStream enables the global stream.  Each save will allocate offsets from a sequence item
+// and write a copy of each record into the stream so that the store may be used as an
+// eventsource.StreamReader.  The sequence item is updated by every save so the stream limits
+// the write throughput of the table
+func WithStream() Option {
+	return func(s *Store) {
+		s.stream = true
+	}
+}
+
+// WithStreamGapTimeout specifies how long Read waits for an allocated stream offset to be
+// committed before aborting it; defaults to 10s.  See Read
+func WithStreamGapTimeout(timeout time.Duration) Option {
+	return func(s *Store) {
+		if timeout > 0 {
+			s.gapTimeout = timeout
+		}
+	}
+}
+
+// Store provides a DynamoDB implementation of eventsource.Store
+type Store struct {
+	api           DynamoDBAPI
+	tableName     string
+	eventsPerItem int
+	stream        bool
+	gapTimeout    time.Duration
+	now           func() time.Time
+
+	mux  sync.Mutex
+	gaps map[uint64]time.Time // offsets found missing by Read and when they were first found
+}
+
+// New returns a Store that persists records to the table specified
+func New(api DynamoDBAPI, tableName string, opts ...Option) *Store {
+	s := &Store{
+		api:           api,
+		tableName:     tableName,
+		eventsPerItem: defaultEventsPerItem,
+		gapTimeout:    defaultGapTimeout,
+		now:           time.Now,
+		gaps:          map[uint64]time.Time{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// partition returns the partition that contains the version specified
+func (s *Store) partition(version int) int {
+	return version / s.eventsPerItem
+}
+
+// recordName returns the name of the attribute that contains the version specified
+func recordName(version int) string {
+	return recordPrefix + strconv.Itoa(version)
+}
+
+// metadataName returns the name of the attribute that contains the metadata of the version
+// specified
+func metadataName(version int) string {
+	return metadataPrefix + strconv.Itoa(version)
+}
+
+// encodeMetadata returns the metadata as a map attribute
+func encodeMetadata(metadata map[string]string) *types.AttributeValueMemberM {
+	value := make(map[string]types.AttributeValue, len(metadata))
+	for k, v := range metadata {
+		value[k] = &types.AttributeValueMemberS{Value: v}
+	}
+	return &types.AttributeValueMemberM{Value: value}
+}
+
+// decodeMetadata reverses encodeMetadata; returns nil if the attribute is not a map
+func decodeMetadata(value types.AttributeValue) map[string]string {
+	m, ok := value.(*types.AttributeValueMemberM)
+	if !ok || len(m.Value) == 0 {
+		return nil
+	}
+	metadata := make(map[string]string, len(m.Value))
+	for k, v := range m.Value {
+		if s, ok := v.(*types.AttributeValueMemberS); ok {
+			metadata[k] = s.Value
+		}
+	}
+	return metadata
+}
+
+// Save implements eventsource.Store.  Returns a *eventsource.ConflictError if any of the record
+// versions have already been saved
+func (s *Store) Save(ctx context.Context, aggregateID string, records ...eventsource.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return s.save(ctx, aggregateID, records[0].Version-1, false, records)
+}
+
+// SaveVersion implements eventsource.VersionedStore.  The condition is enforced by requiring
+// that expectedVersion, when greater than 0, has been saved and that neither expectedVersion + 1
+// nor any of the record versions have been saved; records are expected to begin at
+// expectedVersion + 1
+func (s *Store) SaveVersion(ctx context.Context, aggregateID string, expectedVersion int, records ...eventsource.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return s.save(ctx, aggregateID, expectedVersion, true, records)
+}
+
+// SaveAggregate implements eventsource.AggregateSaver
+func (s *Store) SaveAggregate(ctx context.Context, input eventsource.SaveAggregateInput) error {
+	return s.SaveVersion(ctx, input.AggregateID, input.ExpectedVersion, input.Records...)
+}
+
+// save writes the records in a single request.  When versioned is true, expectedVersion must
+// have been saved
+func (s *Store) save(ctx context.Context, aggregateID string, expectedVersion int, versioned bool, records []eventsource.Record) error {
+	updates, check := s.updates(aggregateID, expectedVersion, versioned, records)
+
+	var offset uint64
+	var streamItems []types.TransactWriteItem
+	if s.stream {
+		var err error
+		offset, err = s.allocate(ctx, len(records))
+		if err != nil {
+			return err
+		}
+		streamItems = s.streamItems(aggregateID, offset, records)
+	}
+
+	var err error
+	if len(updates) == 1 && check == nil && len(streamItems) == 0 {
+		_, err = s.api.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 updates[0].TableName,
+			Key:                       updates[0].Key,
+			UpdateExpression:          updates[0].UpdateExpression,
+			ConditionExpression:       updates[0].ConditionExpression,
+			ExpressionAttributeNames:  updates[0].ExpressionAttributeNames,
+			ExpressionAttributeValues: updates[0].ExpressionAttributeValues,
+		})
+	} else {
+		items := make([]types.TransactWriteItem, 0, len(updates)+1+len(streamItems))
+		for _, update := range updates {
+			items = append(items, types.TransactWriteItem{Update: update})
+		}
+		if check != nil {
+			items = append(items, types.TransactWriteItem{ConditionCheck: check})
+		}
+		items = append(items, streamItems...)
+		if len(items) > maxTransactItems {
+			return fmt.Errorf("unable to save %v records for aggregate, %v: exceeds the %v items allowed in a transaction", len(records), aggregateID, maxTransactItems)
+		}
+
+		_, err = s.api.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+	}
+
+	if err != nil {
+		if len(streamItems) > 0 {
+			// release the allocated offsets so readers need not wait for them to time out
+			s.abort(ctx, offset, len(streamItems))
+			updateCount := len(updates)
+			if check != nil {
+				updateCount++
+			}
+			if isStreamConditionFailed(err, updateCount) {
+				return fmt.Errorf("unable to save records for aggregate, %v: stream offsets were aborted by a reader before the save completed", aggregateID)
+			}
+		}
+		if isConditionFailed(err) {
+			return s.conflict(ctx, aggregateID, expectedVersion)
+		}
+		return fmt.Errorf("unable to save records for aggregate, %v: %v", aggregateID, err)
+	}
+
+	return nil
+}
+
+// updates groups the records by partition and returns one conditional update per partition.
+// When versioned is true and expectedVersion is greater than 0, the update to the partition
+// holding expectedVersion requires that it exists; if no update touches that partition, the
+// requirement is returned as a condition check instead
+func (s *Store) updates(aggregateID string, expectedVersion int, versioned bool, records []eventsource.Record) ([]*types.Update, *types.ConditionCheck) {
+	byPartition := map[int][]eventsource.Record{}
+	for _, record := range records {
+		partition := s.partition(record.Version)
+		byPartition[partition] = append(byPartition[partition], record)
+	}
+
+	partitions := make([]int, 0, len(byPartition))
+	for partition := range byPartition {
+		partitions = append(partitions, partition)
+	}
+	sort.Ints(partitions)
+
+	next := expectedVersion + 1
+	requireExpected := versioned && expectedVersion > 0
+	updates := make([]*types.Update, 0, len(partitions))
+	for _, partition := range partitions {
+		names := map[string]string{}
+		values := map[string]types.AttributeValue{}
+		var sets, conditions []string
+
+		for i, record := range byPartition[partition] {
+			name, value := "#r"+strconv.Itoa(i), ":r"+strconv.Itoa(i)
+			names[name] = recordName(record.Version)
+			values[value] = &types.AttributeValueMemberB{Value: record.Data}
+			sets = append(sets, name+" = "+value)
+			conditions = append(conditions, "attribute_not_exists("+name+")")
+
+			if len(record.Metadata) > 0 {
+				name, value := "#m"+strconv.Itoa(i), ":m"+strconv.Itoa(i)
+				names[name] = metadataName(record.Version)
+				values[value] = encodeMetadata(record.Metadata)
+				sets = append(sets, name+" = "+value)
+			}
+		}
+
+		if next >= 0 && s.partition(next) == partition {
+			if !containsVersion(byPartition[partition], next) {
+				names["#next"] = recordName(next)
+				conditions = append(conditions, "attribute_not_exists(#next)")
+			}
+		}
+
+		if requireExpected && s.partition(expectedVersion) == partition {
+			names["#expected"] = recordName(expectedVersion)
+			conditions = append(conditions, "attribute_exists(#expected)")
+			requireExpected = false
+		}
+
+		updates = append(updates, &types.Update{
+			TableName:                 aws.String(s.tableName),
+			Key:                       key(aggregateID, partition),
+			UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ")),
+			ConditionExpression:       aws.String(strings.Join(conditions, " AND ")),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		})
+	}
+
+	if !requireExpected {
+		return updates, nil
+	}
+	return updates, &types.ConditionCheck{
+		TableName:                aws.String(s.tableName),
+		Key:                      key(aggregateID, s.partition(expectedVersion)),
+		ConditionExpression:      aws.String("attribute_exists(#expected)"),
+		ExpressionAttributeNames: map[string]string{"#expected": recordName(expectedVersion)},
+	}
+}
+
+func containsVersion(records []eventsource.Record, version int) bool {
+	for _, record := range records {
+		if record.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func key(hash string, partition int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		hashKey:  &types.AttributeValueMemberS{Value: hash},
+		rangeKey: &types.AttributeValueMemberN{Value: strconv.Itoa(partition)},
+	}
+}
+
+// isConditionFailed returns true if the error was caused by a failed condition expression
+func isConditionFailed(err error) bool {
+	var conditionFailed *types.ConditionalCheckFailedException
+	if xerrors.As(err, &conditionFailed) {
+		return true
+	}
+
+	var cancelled *types.TransactionCanceledException
+	if xerrors.As(err, &cancelled) {
+		for _, reason := range cancelled.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// conflict returns a *eventsource.ConflictError describing the failed save
+func (s *Store) conflict(ctx context.Context, aggregateID string, expectedVersion int) error {
+	actualVersion, err := s.version(ctx, aggregateID)
+	if err != nil {
+		return err
+	}
+
+	return &eventsource.ConflictError{
+		AggregateID:     aggregateID,
+		ExpectedVersion: expectedVersion,
+		ActualVersion:   actualVersion,
+	}
+}
+
+// version returns the latest version saved for the aggregate or 0 if none
+func (s *Store) version(ctx context.Context, aggregateID string) (int, error) {
+	out, err := s.api.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    aws.String("#key = :key"),
+		ExpressionAttributeNames:  map[string]string{"#key": hashKey},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":key": &types.AttributeValueMemberS{Value: aggregateID}},
+		ScanIndexForward:          aws.Bool(false),
+		ConsistentRead:            aws.Bool(true),
+		Limit:                     aws.Int32(1),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to query version of aggregate, %v: %v", aggregateID, err)
+	}
+
+	version := 0
+	for _, item := range out.Items {
+		for _, record := range decodeItem(item) {
+			if record.Version > version {
+				version = record.Version
+			}
+		}
+	}
+
+	return version, nil
+}
+
+// Load implements eventsource.Store
+func (s *Store) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	condition := "#key = :key AND #part >= :from"
+	values := map[string]types.AttributeValue{
+		":key":  &types.AttributeValueMemberS{Value: aggregateID},
+		":from": &types.AttributeValueMemberN{Value: strconv.Itoa(s.partition(fromVersion))},
+	}
+	if toVersion > 0 {
+		condition = "#key = :key AND #part BETWEEN :from AND :to"
+		values[":to"] = &types.AttributeValueMemberN{Value: strconv.Itoa(s.partition(toVersion))}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    aws.String(condition),
+		ExpressionAttributeNames:  map[string]string{"#key": hashKey, "#part": rangeKey},
+		ExpressionAttributeValues: values,
+		ConsistentRead:            aws.Bool(true),
+	}
+
+	history := eventsource.History{}
+	found := false
+	for {
+		out, err := s.api.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load aggregate, %v: %v", aggregateID, err)
+		}
+
+		for _, item := range out.Items {
+			found = true
+			for _, record := range decodeItem(item) {
+				if v := record.Version; v >= fromVersion && (toVersion == 0 || v <= toVersion) {
+					history = append(history, record)
+				}
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	if !found {
+		exists, err := s.exists(ctx, aggregateID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, eventsource.NewNotFoundError(aggregateID)
+		}
+	}
+
+	sort.Sort(history)
+	return history, nil
+}
+
+// exists returns true if any records have been saved for the aggregate
+func (s *Store) exists(ctx context.Context, aggregateID string) (bool, error) {
+	out, err := s.api.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    aws.String("#key = :key"),
+		ExpressionAttributeNames:  map[string]string{"#key": hashKey},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":key": &types.AttributeValueMemberS{Value: aggregateID}},
+		Limit:                     aws.Int32(1),
+		Select:                    types.SelectCount,
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to load aggregate, %v: %v", aggregateID, err)
+	}
+	return out.Count > 0, nil
+}
+
+// decodeItem extracts the records stored in an item
+func decodeItem(item map[string]types.AttributeValue) eventsource.History {
+	history := make(eventsource.History, 0, len(item))
+	for name, value := range item {
+		if !strings.HasPrefix(name, recordPrefix) {
+			continue
+		}
+		version, err := strconv.Atoi(name[len(recordPrefix):])
+		if err != nil {
+			continue
+		}
+		data, ok := value.(*types.AttributeValueMemberB)
+		if !ok {
+			continue
+		}
+		history = append(history, eventsource.Record{
+			Version:  version,
+			Data:     data.Value,
+			Metadata: decodeMetadata(item[metadataName(version)]),
+		})
+	}
+	sort.Sort(history)
+	return history
+}
@@ -0,0 +1,208 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestStore_updates(t *testing.T) {
+	store := New(nil, "events", WithEventsPerItem(2))
+	updates, check := store.updates("abc", 2, true, []eventsource.Record{
+		{Version: 3, Data: []byte("3")},
+		{Version: 4, Data: []byte("4")},
+		{Version: 5, Data: []byte("5")},
+	})
+	if got, want := len(updates), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	partition := updates[0].Key[rangeKey].(*types.AttributeValueMemberN).Value
+	if got, want := partition, "1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := strings.Count(aws.ToString(updates[0].ConditionExpression), "attribute_not_exists"), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := strings.Count(aws.ToString(updates[1].ConditionExpression), "attribute_not_exists"), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := strings.Count(aws.ToString(updates[0].ConditionExpression), "attribute_exists(#expected)"), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if check != nil {
+		t.Fatalf("got %v; want nil", check)
+	}
+
+	// expectedVersion is held by a partition that none of the records are written to
+	updates, check = store.updates("abc", 3, true, []eventsource.Record{
+		{Version: 4, Data: []byte("4")},
+	})
+	if got, want := len(updates), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if strings.Contains(aws.ToString(updates[0].ConditionExpression), "attribute_exists") {
+		t.Fatalf("got %v; want no attribute_exists", aws.ToString(updates[0].ConditionExpression))
+	}
+	if check == nil {
+		t.Fatalf("got nil; want condition check")
+	}
+	partition = check.Key[rangeKey].(*types.AttributeValueMemberN).Value
+	if got, want := partition, "1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// Save does not require the preceding version to exist
+	if _, check := store.updates("abc", 3, false, []eventsource.Record{{Version: 4}}); check != nil {
+		t.Fatalf("got %v; want nil", check)
+	}
+}
+
+func TestDecodeItem(t *testing.T) {
+	history := decodeItem(map[string]types.AttributeValue{
+		hashKey:  &types.AttributeValueMemberS{Value: "abc"},
+		rangeKey: &types.AttributeValueMemberN{Value: "0"},
+		"_2":     &types.AttributeValueMemberB{Value: []byte("2")},
+		"_1":     &types.AttributeValueMemberB{Value: []byte("1")},
+	})
+	if got, want := len(history), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := history[0].Version, 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := string(history[1].Data), "2"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStreamBucket(t *testing.T) {
+	if got, want := streamBucket(999), streamPrefix+"0"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := streamBucket(1000), streamPrefix+"1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+// streamAPI provides the subset of DynamoDB required to Read the stream from memory
+type streamAPI struct {
+	DynamoDBAPI
+	last  uint64
+	items map[uint64]map[string]types.AttributeValue
+}
+
+func (a *streamAPI) put(item map[string]types.AttributeValue) {
+	offset, _ := strconv.ParseUint(item[rangeKey].(*types.AttributeValueMemberN).Value, 10, 64)
+	a.items[offset] = item
+}
+
+func (a *streamAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			sequenceAttribute: &types.AttributeValueMemberN{Value: strconv.FormatUint(a.last, 10)},
+		},
+	}, nil
+}
+
+func (a *streamAPI) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	bucket := params.ExpressionAttributeValues[":key"].(*types.AttributeValueMemberS).Value
+	from, _ := strconv.ParseUint(params.ExpressionAttributeValues[":from"].(*types.AttributeValueMemberN).Value, 10, 64)
+
+	var offsets []uint64
+	for offset := range a.items {
+		if streamBucket(offset) == bucket && offset >= from {
+			offsets = append(offsets, offset)
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	out := &dynamodb.QueryOutput{}
+	for _, offset := range offsets {
+		if len(out.Items) == int(aws.ToInt32(params.Limit)) {
+			out.LastEvaluatedKey = out.Items[len(out.Items)-1]
+			break
+		}
+		out.Items = append(out.Items, a.items[offset])
+	}
+	return out, nil
+}
+
+func (a *streamAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	offset, _ := strconv.ParseUint(params.Item[rangeKey].(*types.AttributeValueMemberN).Value, 10, 64)
+	if _, ok := a.items[offset]; ok {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	a.items[offset] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestStore_ReadGap(t *testing.T) {
+	ctx := context.Background()
+	api := &streamAPI{last: 3, items: map[uint64]map[string]types.AttributeValue{}}
+
+	now := time.Unix(0, 0)
+	store := New(api, "events", WithStream(), WithStreamGapTimeout(time.Second))
+	store.now = func() time.Time { return now }
+
+	// offset 3 commits before offset 2
+	for _, item := range store.streamItems("a", 1, []eventsource.Record{{Version: 1}}) {
+		api.put(item.Put.Item)
+	}
+	for _, item := range store.streamItems("b", 3, []eventsource.Record{{Version: 1}}) {
+		api.put(item.Put.Item)
+	}
+
+	offsets := func() []uint64 {
+		records, err := store.Read(ctx, 0, 10)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		var offsets []uint64
+		for _, record := range records {
+			offsets = append(offsets, record.Offset)
+		}
+		return offsets
+	}
+
+	// records beyond an uncommitted offset are withheld
+	if got, want := offsets(), []uint64{1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// offset 2 commits within the timeout
+	for _, item := range store.streamItems("c", 2, []eventsource.Record{{Version: 1}}) {
+		api.put(item.Put.Item)
+	}
+	if got, want := offsets(), []uint64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// offset 4 is allocated, but never committed, so it is aborted once the timeout elapses
+	api.last = 5
+	for _, item := range store.streamItems("d", 5, []eventsource.Record{{Version: 1}}) {
+		api.put(item.Put.Item)
+	}
+	if got, want := offsets(), []uint64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	now = now.Add(2 * time.Second)
+	if got, want := offsets(), []uint64{1, 2, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// the aborted offset can no longer be committed
+	_, err := api.PutItem(ctx, &dynamodb.PutItemInput{Item: store.streamItems("e", 4, []eventsource.Record{{Version: 1}})[0].Put.Item})
+	if !isConditionFailed(err) {
+		t.Fatalf("got %v; want condition failed", err)
+	}
+}
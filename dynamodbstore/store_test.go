@@ -0,0 +1,129 @@
+package dynamodbstore_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/dynamodbstore"
+	"github.com/eventsource-ecosystem/eventsource/storetest"
+	"golang.org/x/xerrors"
+)
+
+// newTable creates a table in the DynamoDB Local instance referenced by DYNAMODB_ENDPOINT.  The
+// test is skipped if DYNAMODB_ENDPOINT is not set
+func newTable(t *testing.T) (*dynamodb.Client, string, func()) {
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT not set")
+	}
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "local", SecretAccessKey: "local"}, nil
+		}),
+	})
+
+	ctx := context.Background()
+	tableName := fmt.Sprintf("eventsource-%v", time.Now().UnixNano())
+	if err := dynamodbstore.CreateTable(ctx, client, tableName); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	return client, tableName, func() {
+		dynamodbstore.DeleteTable(ctx, client, tableName)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) eventsource.Store {
+		client, tableName, cleanup := newTable(t)
+		t.Cleanup(cleanup)
+		return dynamodbstore.New(client, tableName, dynamodbstore.WithEventsPerItem(2), dynamodbstore.WithStream())
+	})
+}
+
+func TestStore(t *testing.T) {
+	client, tableName, cleanup := newTable(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	store := dynamodbstore.New(client, tableName, dynamodbstore.WithEventsPerItem(2), dynamodbstore.WithStream())
+
+	err := store.Save(ctx, "a",
+		eventsource.Record{Version: 1, Data: []byte("a1")},
+		eventsource.Record{Version: 2, Data: []byte("a2")},
+		eventsource.Record{Version: 3, Data: []byte("a3")},
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.SaveVersion(ctx, "b", 0, eventsource.Record{Version: 1, Data: []byte("b1")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	history, err := store.Load(ctx, "a", 2, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(history), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := string(history[0].Data), "a2"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	records, err := store.Read(ctx, 3, 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(records), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := records[1].AggregateID, "b"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_NotFound(t *testing.T) {
+	client, tableName, cleanup := newTable(t)
+	defer cleanup()
+
+	store := dynamodbstore.New(client, tableName)
+	_, err := store.Load(context.Background(), "does-not-exist", 0, 0)
+	if !eventsource.IsNotFoundError(err) {
+		t.Fatalf("got %v; want not found", err)
+	}
+}
+
+func TestStore_Conflict(t *testing.T) {
+	client, tableName, cleanup := newTable(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	store := dynamodbstore.New(client, tableName)
+
+	if err := store.SaveVersion(ctx, "a", 0, eventsource.Record{Version: 1, Data: []byte("a1")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	err := store.SaveVersion(ctx, "a", 0, eventsource.Record{Version: 1, Data: []byte("a1")})
+	if !eventsource.IsConflictError(err) {
+		t.Fatalf("got %v; want conflict", err)
+	}
+
+	var conflict *eventsource.ConflictError
+	if ok := xerrors.As(err, &conflict); !ok {
+		t.Fatalf("got false; want true")
+	}
+	if got, want := conflict.ActualVersion, 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
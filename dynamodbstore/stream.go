@@ -0,0 +1,319 @@
+package dynamodbstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/eventsource-ecosystem/eventsource"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// sequenceKey is the hash key of the item that holds the last allocated offset
+	sequenceKey = "$sequence"
+
+	// streamPrefix prefixes the hash key of stream items; stream items are bucketed by offset
+	streamPrefix = "$stream#"
+
+	// streamBucketSize is the number of offsets stored under each stream hash key
+	streamBucketSize = 1000
+
+	sequenceAttribute    = "offset"
+	aggregateIDAttribute = "aggregate_id"
+	versionAttribute     = "version"
+	dataAttribute        = "data"
+	metadataAttribute    = "metadata"
+
+	// abortedAttribute marks a stream item as a tombstone for an offset whose save failed
+	abortedAttribute = "aborted"
+)
+
+var errStreamDisabled = errors.New("dynamodbstore: stream not enabled; see WithStream")
+
+// allocate reserves count offsets from the sequence and returns the first
+func (s *Store) allocate(ctx context.Context, count int) (uint64, error) {
+	out, err := s.api.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.tableName),
+		Key:                       key(sequenceKey, 0),
+		UpdateExpression:          aws.String("ADD #offset :count"),
+		ExpressionAttributeNames:  map[string]string{"#offset": sequenceAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":count": &types.AttributeValueMemberN{Value: strconv.Itoa(count)}},
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to allocate stream offsets: %v", err)
+	}
+
+	last, err := parseOffset(out.Attributes)
+	if err != nil {
+		return 0, err
+	}
+
+	return last - uint64(count) + 1, nil
+}
+
+// streamItems returns the items that copy the records into the stream starting at offset
+func (s *Store) streamItems(aggregateID string, offset uint64, records []eventsource.Record) []types.TransactWriteItem {
+	items := make([]types.TransactWriteItem, 0, len(records))
+	for i, record := range records {
+		o := offset + uint64(i)
+		item := map[string]types.AttributeValue{
+			hashKey:              &types.AttributeValueMemberS{Value: streamBucket(o)},
+			rangeKey:             &types.AttributeValueMemberN{Value: strconv.FormatUint(o, 10)},
+			aggregateIDAttribute: &types.AttributeValueMemberS{Value: aggregateID},
+			versionAttribute:     &types.AttributeValueMemberN{Value: strconv.Itoa(record.Version)},
+			dataAttribute:        &types.AttributeValueMemberB{Value: record.Data},
+		}
+		if len(record.Metadata) > 0 {
+			item[metadataAttribute] = encodeMetadata(record.Metadata)
+		}
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:                aws.String(s.tableName),
+				Item:                     item,
+				ConditionExpression:      aws.String("attribute_not_exists(#key)"),
+				ExpressionAttributeNames: map[string]string{"#key": hashKey},
+			},
+		})
+	}
+	return items
+}
+
+// abort writes tombstones for count offsets beginning at offset.  Offsets that have since been
+// committed, or already aborted, are left as is.  Errors are ignored; offsets that cannot be
+// aborted here will be aborted by Read once WithStreamGapTimeout elapses
+func (s *Store) abort(ctx context.Context, offset uint64, count int) {
+	for i := 0; i < count; i++ {
+		s.tombstone(ctx, offset+uint64(i))
+	}
+}
+
+// tombstone marks the offset as aborted unless a record has already been committed at the
+// offset.  As stream items are written with the condition that the offset does not exist, a
+// tombstone also prevents a save that has stalled from committing at the offset later
+func (s *Store) tombstone(ctx context.Context, offset uint64) error {
+	_, err := s.api.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]types.AttributeValue{
+			hashKey:          &types.AttributeValueMemberS{Value: streamBucket(offset)},
+			rangeKey:         &types.AttributeValueMemberN{Value: strconv.FormatUint(offset, 10)},
+			abortedAttribute: &types.AttributeValueMemberBOOL{Value: true},
+		},
+		ConditionExpression:      aws.String("attribute_not_exists(#key)"),
+		ExpressionAttributeNames: map[string]string{"#key": hashKey},
+	})
+	if err != nil && !isConditionFailed(err) {
+		return fmt.Errorf("unable to abort stream offset, %v: %v", offset, err)
+	}
+	return nil
+}
+
+// isStreamConditionFailed returns true if the transaction was cancelled because one of its
+// stream items, those following the first updateCount items, already existed
+func isStreamConditionFailed(err error, updateCount int) bool {
+	var cancelled *types.TransactionCanceledException
+	if !xerrors.As(err, &cancelled) {
+		return false
+	}
+	for i, reason := range cancelled.CancellationReasons {
+		if i >= updateCount && aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+			return true
+		}
+	}
+	return false
+}
+
+// streamBucket returns the hash key of the stream item for the offset specified
+func streamBucket(offset uint64) string {
+	return streamPrefix + strconv.FormatUint(offset/streamBucketSize, 10)
+}
+
+func parseOffset(item map[string]types.AttributeValue) (uint64, error) {
+	v, ok := item[sequenceAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, nil
+	}
+	offset, err := strconv.ParseUint(v.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stream offset, %v: %v", v.Value, err)
+	}
+	return offset, nil
+}
+
+// Read implements eventsource.StreamReader; requires WithStream.  Offsets begin at 1.
+//
+// Offsets are allocated before the save that uses them commits, so concurrent saves may commit
+// out of order.  Read never skips an offset that has been allocated but not yet committed;
+// records are returned up to the first such offset.  If the offset remains missing for longer
+// than WithStreamGapTimeout, Read aborts it so that the stream may continue.  An aborted offset
+// can no longer be committed; the save that allocated it fails
+func (s *Store) Read(ctx context.Context, startingOffset uint64, recordCount int) ([]eventsource.StreamRecord, error) {
+	if !s.stream {
+		return nil, errStreamDisabled
+	}
+	if startingOffset == 0 {
+		startingOffset = 1
+	}
+
+	out, err := s.api.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            key(sequenceKey, 0),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read stream offset: %v", err)
+	}
+	last, err := parseOffset(out.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []eventsource.StreamRecord
+	offset := startingOffset
+	defer func() { s.forgetGaps(offset) }()
+
+	for offset <= last && len(records) < recordCount {
+		bucket := offset / streamBucketSize
+		limit := recordCount - len(records)
+		if limit > math.MaxInt32 {
+			limit = math.MaxInt32
+		}
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(s.tableName),
+			KeyConditionExpression: aws.String("#key = :key AND #part >= :from"),
+			ExpressionAttributeNames: map[string]string{
+				"#key":  hashKey,
+				"#part": rangeKey,
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":key":  &types.AttributeValueMemberS{Value: streamBucket(offset)},
+				":from": &types.AttributeValueMemberN{Value: strconv.FormatUint(offset, 10)},
+			},
+			ConsistentRead: aws.Bool(true),
+			Limit:          aws.Int32(int32(limit)),
+		}
+
+		page, err := s.api.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read stream from offset, %v: %v", offset, err)
+		}
+
+		gap := false
+		for _, item := range page.Items {
+			record, aborted, err := decodeStreamItem(item)
+			if err != nil {
+				return nil, err
+			}
+			if record.Offset != offset {
+				gap = true
+				break
+			}
+			if !aborted {
+				records = append(records, record)
+			}
+			offset++
+		}
+
+		// the remainder of the bucket has been read, yet offset is neither in a later bucket
+		// nor beyond the last offset allocated
+		if !gap && len(page.LastEvaluatedKey) == 0 && offset/streamBucketSize == bucket && offset <= last {
+			gap = true
+		}
+
+		if gap {
+			aborted, err := s.resolveGap(ctx, offset)
+			if err != nil {
+				return nil, err
+			}
+			if !aborted {
+				break
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// resolveGap is called when offset has been allocated but not committed.  Returns true if the
+// offset was missing for longer than the gap timeout and has now been aborted, or committed
+func (s *Store) resolveGap(ctx context.Context, offset uint64) (bool, error) {
+	now := s.now()
+
+	s.mux.Lock()
+	since, ok := s.gaps[offset]
+	if !ok {
+		s.gaps[offset] = now
+	}
+	s.mux.Unlock()
+
+	if !ok || now.Sub(since) < s.gapTimeout {
+		return false, nil
+	}
+
+	if err := s.tombstone(ctx, offset); err != nil {
+		return false, err
+	}
+
+	s.mux.Lock()
+	delete(s.gaps, offset)
+	s.mux.Unlock()
+
+	return true, nil
+}
+
+// forgetGaps discards the gaps recorded before offset as they have since been resolved
+func (s *Store) forgetGaps(offset uint64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for gap := range s.gaps {
+		if gap < offset {
+			delete(s.gaps, gap)
+		}
+	}
+}
+
+// decodeStreamItem returns the record contained in the stream item.  Returns true if the item
+// is a tombstone for an aborted offset, in which case only the offset of the record is set
+func decodeStreamItem(item map[string]types.AttributeValue) (eventsource.StreamRecord, bool, error) {
+	offset, _ := item[rangeKey].(*types.AttributeValueMemberN)
+	if aborted, ok := item[abortedAttribute].(*types.AttributeValueMemberBOOL); ok && aborted.Value && offset != nil {
+		o, err := strconv.ParseUint(offset.Value, 10, 64)
+		if err != nil {
+			return eventsource.StreamRecord{}, false, fmt.Errorf("invalid stream offset, %v: %v", offset.Value, err)
+		}
+		return eventsource.StreamRecord{Offset: o}, true, nil
+	}
+
+	aggregateID, _ := item[aggregateIDAttribute].(*types.AttributeValueMemberS)
+	version, _ := item[versionAttribute].(*types.AttributeValueMemberN)
+	data, _ := item[dataAttribute].(*types.AttributeValueMemberB)
+	if offset == nil || aggregateID == nil || version == nil || data == nil {
+		return eventsource.StreamRecord{}, false, fmt.Errorf("invalid stream item, %v", item)
+	}
+
+	o, err := strconv.ParseUint(offset.Value, 10, 64)
+	if err != nil {
+		return eventsource.StreamRecord{}, false, fmt.Errorf("invalid stream offset, %v: %v", offset.Value, err)
+	}
+	v, err := strconv.Atoi(version.Value)
+	if err != nil {
+		return eventsource.StreamRecord{}, false, fmt.Errorf("invalid stream version, %v: %v", version.Value, err)
+	}
+
+	return eventsource.StreamRecord{
+		Record: eventsource.Record{
+			Version:  v,
+			Data:     data.Value,
+			Metadata: decodeMetadata(item[metadataAttribute]),
+		},
+		Offset:      o,
+		AggregateID: aggregateID.Value,
+	}, false, nil
+}
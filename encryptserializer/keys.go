@@ -0,0 +1,102 @@
+package encryptserializer
+
+import (
+	"fmt"
+	"sync"
+)
+
+// KeyProvider supplies the AES keys records are encrypted with.  Each key is identified by an
+// id that is stored alongside every record it encrypts so that, after the current key is
+// rotated, records encrypted with retired keys can still be decrypted
+type KeyProvider interface {
+	// CurrentKey returns the id and key new records should be encrypted with
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the id specified
+	Key(id string) ([]byte, error)
+}
+
+// KeyRing provides an in-memory KeyProvider.  Keys are never removed from a KeyRing; rotating
+// the current key retires the previous key from encryption only
+type KeyRing struct {
+	mux     sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyRing returns a KeyRing that encrypts with key, identified by id
+func NewKeyRing(id string, key []byte) (*KeyRing, error) {
+	k := &KeyRing{
+		keys: map[string][]byte{},
+	}
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Add makes a retired key, identified by id, available for decryption
+func (k *KeyRing) Add(id string, key []byte) error {
+	if err := validateKey(id, key); err != nil {
+		return err
+	}
+
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	if existing, ok := k.keys[id]; ok && string(existing) != string(key) {
+		return fmt.Errorf("key, %v, already exists with a different value", id)
+	}
+	k.keys[id] = append([]byte(nil), key...)
+	return nil
+}
+
+// Rotate adds key, identified by id, and makes it the key new records are encrypted with
+func (k *KeyRing) Rotate(id string, key []byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	k.current = id
+	return nil
+}
+
+// CurrentKey implements KeyProvider
+func (k *KeyRing) CurrentKey() (string, []byte, error) {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+
+	return k.current, k.keys[k.current], nil
+}
+
+// Key implements KeyProvider
+func (k *KeyRing) Key(id string) ([]byte, error) {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key, %v, not found", id)
+	}
+	return key, nil
+}
+
+// validateKey ensures the id may be stored in a record and the key selects AES-128, AES-192,
+// or AES-256
+func validateKey(id string, key []byte) error {
+	if id == "" {
+		return fmt.Errorf("key id must not be empty")
+	}
+	if len(id) > maxKeyIDSize {
+		return fmt.Errorf("key id, %v, exceeds %v bytes", id, maxKeyIDSize)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	default:
+		return fmt.Errorf("key, %v, must be 16, 24, or 32 bytes; got %v", id, len(key))
+	}
+}
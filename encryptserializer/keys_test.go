@@ -0,0 +1,66 @@
+package encryptserializer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource/encryptserializer"
+)
+
+func TestKeyRing(t *testing.T) {
+	keys := newKeyRing(t, "a", key(1))
+
+	if err := keys.Add("b", key(2)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	id, _, err := keys.CurrentKey()
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := id, "a"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if err := keys.Rotate("c", key(3)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	id, current, err := keys.CurrentKey()
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := id, "c"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := string(current), string(key(3)); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := keys.Key(id); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+	if _, err := keys.Key("d"); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
+
+func TestKeyRing_Errors(t *testing.T) {
+	if _, err := encryptserializer.NewKeyRing("", key(1)); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	if _, err := encryptserializer.NewKeyRing(strings.Repeat("a", 256), key(1)); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	if _, err := encryptserializer.NewKeyRing("a", []byte("short")); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+
+	keys := newKeyRing(t, "a", key(1))
+	if err := keys.Add("a", key(2)); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	if err := keys.Add("a", key(1)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Package encryptserializer provides an eventsource.Serializer that encrypts the records
+// written by another Serializer with AES-GCM.
+//
+// Each record contains a version byte, the id of the key it was encrypted with, a random nonce,
+// and the sealed data.  The version byte, key id, and Record.Version are authenticated along with
+// the data so a ciphertext copied to another version of a stream fails to decrypt.  The
+// aggregate id is not available to a Serializer and is not authenticated; a ciphertext copied
+// to the same version of another aggregate still decrypts.  Keys
+// are supplied by a KeyProvider; rotating the current key leaves records encrypted with retired
+// keys readable for as long as the provider retains them.
+//
+// Encrypted data does not compress, so wrap a compressserializer.Serializer rather than the
+// reverse when both are required:
+//
+//	serializer := encryptserializer.New(compressserializer.New(eventsource.NewJSONSerializer(...)), keys)
+//
+// Record.Metadata is not encrypted.
+package encryptserializer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// version identifies the layout of encrypted records
+const version = 1
+
+// maxKeyIDSize is the largest key id that may be stored in a record
+const maxKeyIDSize = 255
+
+// Serializer encrypts the records written by another Serializer
+type Serializer struct {
+	serializer eventsource.Serializer
+	keys       KeyProvider
+}
+
+// New returns a Serializer that encrypts the records written by serializer with the keys
+// provided by keys
+func New(serializer eventsource.Serializer, keys KeyProvider) *Serializer {
+	return &Serializer{
+		serializer: serializer,
+		keys:       keys,
+	}
+}
+
+// MarshalEvent implements eventsource.Serializer
+func (s *Serializer) MarshalEvent(event eventsource.Event) (eventsource.Record, error) {
+	record, err := s.serializer.MarshalEvent(event)
+	if err != nil {
+		return eventsource.Record{}, err
+	}
+
+	id, key, err := s.keys.CurrentKey()
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to encrypt record at version, %v: %v", record.Version, err)
+	}
+	if err := validateKey(id, key); err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to encrypt record at version, %v: %v", record.Version, err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to encrypt record at version, %v, with key, %v: %v", record.Version, id, err)
+	}
+
+	header := make([]byte, 0, 2+len(id)+aead.NonceSize())
+	header = append(header, version, byte(len(id)))
+	header = append(header, id...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to encrypt record at version, %v: %v", record.Version, err)
+	}
+
+	data := make([]byte, 0, len(header)+len(nonce)+len(record.Data)+aead.Overhead())
+	data = append(data, header...)
+	data = append(data, nonce...)
+	data = aead.Seal(data, nonce, record.Data, additionalData(header, record.Version))
+	record.Data = data
+
+	return record, nil
+}
+
+// UnmarshalEvent implements eventsource.Serializer
+func (s *Serializer) UnmarshalEvent(record eventsource.Record) (eventsource.Event, error) {
+	record, err := s.decrypt(record)
+	if err != nil {
+		return nil, err
+	}
+	return s.serializer.UnmarshalEvent(record)
+}
+
+// UnmarshalEvents implements eventsource.EventsUnmarshaler
+func (s *Serializer) UnmarshalEvents(record eventsource.Record) ([]eventsource.Event, error) {
+	record, err := s.decrypt(record)
+	if err != nil {
+		return nil, err
+	}
+	return eventsource.UnmarshalEvents(s.serializer, record)
+}
+
+// KeyID returns the id of the key the record was encrypted with
+func KeyID(record eventsource.Record) (string, error) {
+	id, _, err := parseHeader(record)
+	return id, err
+}
+
+// decrypt returns the record with its data decrypted using the key it was encrypted with
+func (s *Serializer) decrypt(record eventsource.Record) (eventsource.Record, error) {
+	id, headerSize, err := parseHeader(record)
+	if err != nil {
+		return eventsource.Record{}, err
+	}
+
+	key, err := s.keys.Key(id)
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to decrypt record at version, %v: %v", record.Version, err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to decrypt record at version, %v, with key, %v: %v", record.Version, id, err)
+	}
+
+	header, rest := record.Data[:headerSize], record.Data[headerSize:]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return eventsource.Record{}, fmt.Errorf("unable to decrypt record at version, %v: record is truncated", record.Version)
+	}
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+	data, err := aead.Open(nil, nonce, sealed, additionalData(header, record.Version))
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to decrypt record at version, %v, with key, %v: %v", record.Version, id, err)
+	}
+
+	record.Data = data
+	return record, nil
+}
+
+// parseHeader returns the key id stored in the record along with the size of the header
+func parseHeader(record eventsource.Record) (string, int, error) {
+	data := record.Data
+	if len(data) < 2 || data[0] != version {
+		return "", 0, fmt.Errorf("record at version, %v, is not encrypted", record.Version)
+	}
+
+	size := 2 + int(data[1])
+	if len(data) < size {
+		return "", 0, fmt.Errorf("record at version, %v, is truncated", record.Version)
+	}
+	return string(data[2:size]), size, nil
+}
+
+// additionalData returns the data authenticated, but not encrypted, with the record; the header
+// followed by the record version
+func additionalData(header []byte, recordVersion int) []byte {
+	ad := make([]byte, len(header), len(header)+8)
+	copy(ad, header)
+	return binary.BigEndian.AppendUint64(ad, uint64(recordVersion))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,159 @@
+package encryptserializer_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/encryptserializer"
+)
+
+type Patient struct {
+	ID        string
+	Diagnosis string
+}
+
+type DiagnosisRecorded struct {
+	eventsource.Model
+	Diagnosis string
+}
+
+func (p *Patient) On(event eventsource.Event) error {
+	switch v := event.(type) {
+	case *DiagnosisRecorded:
+		p.ID = v.ID
+		p.Diagnosis = v.Diagnosis
+	default:
+		return fmt.Errorf("unhandled event, %T", event)
+	}
+	return nil
+}
+
+func key(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func newKeyRing(t *testing.T, id string, key []byte) *encryptserializer.KeyRing {
+	keys, err := encryptserializer.NewKeyRing(id, key)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	return keys
+}
+
+func TestSerializer_Rotation(t *testing.T) {
+	ctx := context.Background()
+	store := eventsource.NewMemoryStore()
+	keys := newKeyRing(t, "2020-01", key(1))
+
+	serializer := encryptserializer.New(
+		eventsource.NewJSONSerializer(DiagnosisRecorded{}),
+		keys,
+	)
+	repo := eventsource.New(&Patient{},
+		eventsource.WithStore(store),
+		eventsource.WithSerializer(serializer),
+	)
+
+	err := repo.Save(eventsource.ContextWithActor(ctx, "dr-who"), &DiagnosisRecorded{
+		Model:     eventsource.Model{ID: "abc", Version: 1},
+		Diagnosis: "sensitive",
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if err := keys.Rotate("2020-02", key(2)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	err = repo.Save(ctx, &DiagnosisRecorded{
+		Model:     eventsource.Model{ID: "abc", Version: 2},
+		Diagnosis: "more sensitive",
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	history, err := store.Load(ctx, "abc", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	for i, want := range []string{"2020-01", "2020-02"} {
+		got, err := encryptserializer.KeyID(history[i])
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if bytes.Contains(history[i].Data, []byte("sensitive")) {
+			t.Fatalf("got %q; want encrypted", history[i].Data)
+		}
+	}
+	if got, want := history[0].Metadata[eventsource.MetadataActor], "dr-who"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// records encrypted with the retired key still load
+	v, version, err := repo.Load(ctx, "abc")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := version, 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := v.(*Patient).Diagnosis, "more sensitive"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSerializer_Errors(t *testing.T) {
+	serializer := encryptserializer.New(eventsource.NewJSONSerializer(DiagnosisRecorded{}), newKeyRing(t, "a", key(1)))
+
+	record, err := serializer.MarshalEvent(&DiagnosisRecorded{Model: eventsource.Model{ID: "abc", Version: 1}})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := record
+		tampered.Data = append([]byte(nil), record.Data...)
+		tampered.Data[len(tampered.Data)-1] ^= 0xff
+		if _, err := serializer.UnmarshalEvent(tampered); err == nil {
+			t.Fatalf("got nil; want not nil")
+		}
+	})
+
+	t.Run("moved", func(t *testing.T) {
+		moved := record
+		moved.Version = record.Version + 1
+		if _, err := serializer.UnmarshalEvent(moved); err == nil {
+			t.Fatalf("got nil; want not nil")
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		other := encryptserializer.New(eventsource.NewJSONSerializer(DiagnosisRecorded{}), newKeyRing(t, "b", key(1)))
+		if _, err := other.UnmarshalEvent(record); err == nil {
+			t.Fatalf("got nil; want not nil")
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other := encryptserializer.New(eventsource.NewJSONSerializer(DiagnosisRecorded{}), newKeyRing(t, "a", key(2)))
+		if _, err := other.UnmarshalEvent(record); err == nil {
+			t.Fatalf("got nil; want not nil")
+		}
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		for _, data := range [][]byte{nil, []byte("{}"), {1, 10, 'a'}, record.Data[:len(record.Data)-20]} {
+			if _, err := serializer.UnmarshalEvent(eventsource.Record{Data: data}); err == nil {
+				t.Fatalf("got nil; want not nil")
+			}
+		}
+	})
+}
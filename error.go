@@ -1,6 +1,10 @@
 package eventsource
 
-import "golang.org/x/xerrors"
+import (
+	"fmt"
+
+	"golang.org/x/xerrors"
+)
 
 type errorType string
 
@@ -31,9 +35,63 @@ const (
 	// UnhandledEvent occurs when the Aggregate is unable to handle an event and returns
 	// a non-nil err
 	errUnhandledEvent errorType = "UnhandledEvent"
+
+	// ObserverOverflow is reported when a notification is dropped because the async observer
+	// queue is full
+	errObserverOverflow errorType = "ObserverOverflow"
 )
 
 // IsNotFound returns true if the error was AggregateNotFound
 func IsNotFoundError(err error) bool {
 	return xerrors.Is(err, errAggregateNotFound)
 }
+
+// IsUnboundEventTypeError returns true if the error was UnboundEventType i.e. the Serializer
+// does not recognize the type of the serialized event
+func IsUnboundEventTypeError(err error) bool {
+	return xerrors.Is(err, errUnboundEventType)
+}
+
+// IsObserverOverflowError returns true if the error indicates a notification was dropped
+// because the async observer queue was full
+func IsObserverOverflowError(err error) bool {
+	return xerrors.Is(err, errObserverOverflow)
+}
+
+// ConflictError is returned by a Store when the records provided could not be saved because
+// the aggregate was modified after expectedVersion was loaded
+type ConflictError struct {
+	// AggregateID identifies the aggregate whose save was rejected
+	AggregateID string
+
+	// ExpectedVersion contains the version the caller believed to be current
+	ExpectedVersion int
+
+	// ActualVersion contains the version found in the store
+	ActualVersion int
+}
+
+// Error implements the error interface
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("concurrency conflict saving aggregate, %v: expected version %v; found version %v",
+		e.AggregateID, e.ExpectedVersion, e.ActualVersion)
+}
+
+// IsConflictError returns true if the error was, or wraps, a *ConflictError
+func IsConflictError(err error) bool {
+	var conflict *ConflictError
+	return xerrors.As(err, &conflict)
+}
+
+// NewNotFoundError returns an error, detectable with IsNotFoundError, indicating that the
+// aggregate does not exist.  Intended for use by Store implementations
+func NewNotFoundError(aggregateID string) error {
+	return xerrors.Errorf("no aggregate found with id, %v: %w", aggregateID, errAggregateNotFound)
+}
+
+// NewUnboundEventTypeError returns an error, detectable with IsUnboundEventTypeError, indicating
+// that the serialized event type has not been bound.  Intended for use by Serializer
+// implementations
+func NewUnboundEventTypeError(eventType string) error {
+	return xerrors.Errorf("unbound event type, %v: %w", eventType, errUnboundEventType)
+}
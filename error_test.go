@@ -1,9 +1,23 @@
 package eventsource
 
-import "testing"
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+)
 
 func Test_errorType_Error(t *testing.T) {
 	if got, want := errAggregateNotFound.Error(), string(errAggregateNotFound); got != want {
 		t.Fatalf("got %v; want %v", got, want)
 	}
 }
+
+func TestIsConflictError(t *testing.T) {
+	err := xerrors.Errorf("unable to save: %w", &ConflictError{AggregateID: "abc", ExpectedVersion: 1, ActualVersion: 2})
+	if !IsConflictError(err) {
+		t.Fatalf("got false; want true")
+	}
+	if IsConflictError(errAggregateNotFound) {
+		t.Fatalf("got true; want false")
+	}
+}
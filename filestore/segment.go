@@ -0,0 +1,339 @@
+package filestore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const (
+	// segmentExt is the file extension used by segment files
+	segmentExt = ".seg"
+
+	// headerSize is the size of the length prefix plus the crc checksum preceding each frame
+	headerSize = 8
+
+	// frameVersion identifies the layout of the frame payload written by this package
+	frameVersion = 1
+
+	// maxFrameSize limits the size of a single frame; larger lengths are treated as corruption
+	maxFrameSize = 1 << 30
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// errTornFrame indicates the frame was only partially written or failed its checksum
+	errTornFrame = errors.New("torn frame")
+)
+
+// location identifies where a single record is stored
+type location struct {
+	offset       uint64
+	aggregateID  string
+	version      int
+	segment      *segment
+	position     int64 // position of the record data within the segment
+	size         int   // size of the record data
+	metaPosition int64 // position of the encoded record metadata within the segment
+	metaSize     int   // size of the encoded record metadata
+}
+
+// segment represents a single append-only segment file
+type segment struct {
+	file *os.File
+	path string
+	base uint64 // offset of the first record in the segment
+	size int64  // number of valid bytes in the segment
+}
+
+// segmentPath returns the path of the segment whose first record has the offset specified
+func segmentPath(dir string, base uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%v", base, segmentExt))
+}
+
+// listSegments returns the base offsets of the segments in dir, in ascending order
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var bases []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+
+	return bases, nil
+}
+
+// encodeFrame encodes the records into a single frame so that a Save is persisted atomically.
+// The frame is prefixed by the length and crc32 checksum of the payload
+func encodeFrame(offset uint64, aggregateID string, records []eventsource.Record) []byte {
+	buf := make([]byte, headerSize, headerSize+64+len(aggregateID))
+	buf = append(buf, frameVersion)
+	buf = appendUvarint(buf, offset)
+	buf = appendUvarint(buf, uint64(len(aggregateID)))
+	buf = append(buf, aggregateID...)
+	buf = appendUvarint(buf, uint64(len(records)))
+
+	for _, record := range records {
+		buf = appendVarint(buf, int64(record.Version))
+		buf = appendUvarint(buf, uint64(len(record.Data)))
+		buf = append(buf, record.Data...)
+
+		metadata := encodeMetadata(record.Metadata)
+		buf = appendUvarint(buf, uint64(len(metadata)))
+		buf = append(buf, metadata...)
+	}
+
+	payload := buf[headerSize:]
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+
+	return buf
+}
+
+// encodeMetadata encodes the metadata as a count followed by length prefixed keys and values,
+// sorted by key
+func encodeMetadata(metadata map[string]string) []byte {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := appendUvarint(nil, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendUvarint(buf, uint64(len(k)))
+		buf = append(buf, k...)
+		buf = appendUvarint(buf, uint64(len(metadata[k])))
+		buf = append(buf, metadata[k]...)
+	}
+	return buf
+}
+
+// decodeMetadata reverses encodeMetadata; returns nil when data is empty
+func decodeMetadata(data []byte) (map[string]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	f := &frameReader{data: data}
+	count := f.uvarint()
+	if f.err != nil || count > uint64(len(data)) {
+		return nil, errTornFrame
+	}
+
+	metadata := make(map[string]string, int(count))
+	for i := uint64(0); i < count; i++ {
+		k := string(f.bytes(f.uvarint()))
+		v := string(f.bytes(f.uvarint()))
+		if f.err != nil {
+			return nil, f.err
+		}
+		metadata[k] = v
+	}
+	return metadata, nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	return append(buf, scratch[:n]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(scratch[:], v)
+	return append(buf, scratch[:n]...)
+}
+
+// frameReader decodes the fields of a frame payload
+type frameReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (f *frameReader) uvarint() uint64 {
+	if f.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(f.data[f.pos:])
+	if n <= 0 {
+		f.err = errTornFrame
+		return 0
+	}
+	f.pos += n
+	return v
+}
+
+func (f *frameReader) varint() int64 {
+	if f.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(f.data[f.pos:])
+	if n <= 0 {
+		f.err = errTornFrame
+		return 0
+	}
+	f.pos += n
+	return v
+}
+
+func (f *frameReader) bytes(n uint64) []byte {
+	if f.err != nil {
+		return nil
+	}
+	if n > uint64(len(f.data)-f.pos) {
+		f.err = errTornFrame
+		return nil
+	}
+	v := f.data[f.pos : f.pos+int(n)]
+	f.pos += int(n)
+	return v
+}
+
+// scan reads each frame in the segment from the beginning, invoking fn with the locations of the
+// records contained in the frame.  Scanning stops at the first torn or corrupt frame; the size
+// of the segment is set to the end of the last valid frame
+func (s *segment) scan(fn func(locations []location) error) error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := io.NewSectionReader(s.file, 0, info.Size())
+	header := make([]byte, headerSize)
+	position := int64(0)
+	s.size = 0
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errTornFrame
+		}
+
+		length := binary.BigEndian.Uint32(header[0:4])
+		checksum := binary.BigEndian.Uint32(header[4:8])
+		if length == 0 || length > maxFrameSize || int64(length) > info.Size()-position-headerSize {
+			return errTornFrame
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return errTornFrame
+		}
+		if crc32.Checksum(payload, crcTable) != checksum {
+			return errTornFrame
+		}
+
+		locations, err := s.decode(position, payload)
+		if err != nil {
+			return err
+		}
+		if err := fn(locations); err != nil {
+			return err
+		}
+
+		position += headerSize + int64(length)
+		s.size = position
+	}
+}
+
+// decode extracts the record locations from a frame payload that begins at position
+func (s *segment) decode(position int64, payload []byte) ([]location, error) {
+	f := &frameReader{data: payload}
+	if version := f.bytes(1); f.err == nil && version[0] != frameVersion {
+		return nil, fmt.Errorf("unsupported frame version, %v, in segment, %v", version[0], s.path)
+	}
+
+	offset := f.uvarint()
+	aggregateID := string(f.bytes(f.uvarint()))
+	count := f.uvarint()
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if count > uint64(len(payload)) {
+		return nil, errTornFrame
+	}
+
+	locations := make([]location, 0, int(count))
+	for i := uint64(0); i < count; i++ {
+		version := f.varint()
+		size := f.uvarint()
+		start := f.pos
+		f.bytes(size)
+		metaSize := f.uvarint()
+		metaStart := f.pos
+		f.bytes(metaSize)
+		if f.err != nil {
+			return nil, f.err
+		}
+
+		locations = append(locations, location{
+			offset:       offset + i,
+			aggregateID:  aggregateID,
+			version:      int(version),
+			segment:      s,
+			position:     position + headerSize + int64(start),
+			size:         int(size),
+			metaPosition: position + headerSize + int64(metaStart),
+			metaSize:     int(metaSize),
+		})
+	}
+
+	return locations, nil
+}
+
+// read returns the record at the location specified
+func (l location) read() (eventsource.Record, error) {
+	data := make([]byte, l.size)
+	if _, err := l.segment.file.ReadAt(data, l.position); err != nil {
+		return eventsource.Record{}, err
+	}
+
+	record := eventsource.Record{
+		Version: l.version,
+		Data:    data,
+	}
+	if l.metaSize > 0 {
+		encoded := make([]byte, l.metaSize)
+		if _, err := l.segment.file.ReadAt(encoded, l.metaPosition); err != nil {
+			return eventsource.Record{}, err
+		}
+		metadata, err := decodeMetadata(encoded)
+		if err != nil {
+			return eventsource.Record{}, err
+		}
+		record.Metadata = metadata
+	}
+
+	return record, nil
+}
@@ -0,0 +1,476 @@
+// Package filestore provides a durable, embedded eventsource.Store that appends records to
+// segment files on the local filesystem.
+//
+// Each Save is written as a single frame prefixed by its length and a crc32 checksum so that a
+// partially written frame can be detected.  When the store is opened, segments are scanned to
+// rebuild the per aggregate index and any torn frame at the tail of the last segment is
+// truncated.  Every record is assigned a global offset, starting at 1, which allows the store to
+// serve as an eventsource.StreamReader and, for consumers in the same process, an
+// eventsource.StreamNotifier.
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// SyncPolicy specifies when segment files are flushed to stable storage
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes the segment before each Save returns; the default
+	SyncAlways SyncPolicy = iota
+
+	// SyncInterval flushes the segment periodically in the background; a crash may lose saves
+	// made since the last flush
+	SyncInterval
+
+	// SyncNever leaves flushing to the operating system; segments are only flushed on Close
+	SyncNever
+)
+
+const (
+	defaultSegmentSize  = 64 << 20
+	defaultSyncInterval = time.Second
+)
+
+// Option provides functional configuration for a *Store
+type Option func(*Store)
+
+// WithSync specifies the SyncPolicy used by the store
+func WithSync(policy SyncPolicy) Option {
+	return func(s *Store) {
+		s.syncPolicy = policy
+	}
+}
+
+// WithSyncInterval flushes segments in the background at the interval specified; implies
+// SyncInterval
+func WithSyncInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.syncPolicy = SyncInterval
+		s.syncInterval = interval
+	}
+}
+
+// WithSegmentSize specifies the size, in bytes, after which a new segment will be started.
+// Frames are never split so segments may grow slightly larger than size
+func WithSegmentSize(size int64) Option {
+	return func(s *Store) {
+		s.segmentSize = size
+	}
+}
+
+// Store provides a file backed implementation of eventsource.Store
+type Store struct {
+	dir          string
+	segmentSize  int64
+	syncPolicy   SyncPolicy
+	syncInterval time.Duration
+
+	mux      sync.RWMutex
+	segments []*segment            // segments in ascending order; the last is active
+	records  []location            // records indexed by offset - 1
+	byID     map[string][]location // records indexed by aggregate id in version order
+	dirty    bool                  // true when the active segment has unflushed writes
+	closed   bool
+
+	notifyMux sync.Mutex
+	notify    chan struct{} // closed when records are appended
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open opens the store contained in dir, creating dir if necessary
+func Open(dir string, opts ...Option) (*Store, error) {
+	s := &Store{
+		dir:          dir,
+		segmentSize:  defaultSegmentSize,
+		syncPolicy:   SyncAlways,
+		syncInterval: defaultSyncInterval,
+		byID:         map[string][]location{},
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create directory, %v: %v", dir, err)
+	}
+
+	if err := s.recover(); err != nil {
+		s.closeSegments()
+		return nil, err
+	}
+
+	if s.syncPolicy == SyncInterval {
+		if s.syncInterval <= 0 {
+			s.syncInterval = defaultSyncInterval
+		}
+		s.wg.Add(1)
+		go s.syncLoop()
+	}
+
+	return s, nil
+}
+
+// recover rebuilds the index from the segments on disk, truncating any torn frame found at the
+// end of the last segment
+func (s *Store) recover() error {
+	bases, err := listSegments(s.dir)
+	if err != nil {
+		return fmt.Errorf("unable to list segments in %v: %v", s.dir, err)
+	}
+
+	for i, base := range bases {
+		path := segmentPath(s.dir, base)
+		file, err := os.OpenFile(path, os.O_RDWR, 0644)
+		if err != nil {
+			return fmt.Errorf("unable to open segment, %v: %v", path, err)
+		}
+
+		seg := &segment{file: file, path: path, base: base}
+		s.segments = append(s.segments, seg)
+
+		err = seg.scan(func(locations []location) error {
+			for _, loc := range locations {
+				if want := uint64(len(s.records)) + 1; loc.offset != want {
+					return fmt.Errorf("segment, %v, contains offset %v; want %v", path, loc.offset, want)
+				}
+				s.index(loc)
+			}
+			return nil
+		})
+		if err == errTornFrame {
+			if last := i == len(bases)-1; !last {
+				return fmt.Errorf("segment, %v, is corrupt at position %v", path, seg.size)
+			}
+			if err := file.Truncate(seg.size); err != nil {
+				return fmt.Errorf("unable to truncate torn write in segment, %v: %v", path, err)
+			}
+			if err := file.Sync(); err != nil {
+				return fmt.Errorf("unable to sync segment, %v: %v", path, err)
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if len(s.segments) == 0 {
+		return s.roll()
+	}
+
+	return nil
+}
+
+// index adds the location to the in-memory indexes
+func (s *Store) index(loc location) {
+	s.records = append(s.records, loc)
+
+	locations := append(s.byID[loc.aggregateID], loc)
+	if n := len(locations); n > 1 && locations[n-2].version > loc.version {
+		sort.SliceStable(locations, func(i, j int) bool { return locations[i].version < locations[j].version })
+	}
+	s.byID[loc.aggregateID] = locations
+}
+
+// roll starts a new active segment
+func (s *Store) roll() error {
+	if active := s.active(); active != nil {
+		if err := active.file.Sync(); err != nil {
+			return fmt.Errorf("unable to sync segment, %v: %v", active.path, err)
+		}
+		s.dirty = false
+	}
+
+	base := uint64(len(s.records)) + 1
+	path := segmentPath(s.dir, base)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to create segment, %v: %v", path, err)
+	}
+	s.segments = append(s.segments, &segment{file: file, path: path, base: base})
+
+	return syncDir(s.dir)
+}
+
+// active returns the segment currently being appended to
+func (s *Store) active() *segment {
+	if len(s.segments) == 0 {
+		return nil
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+// Save implements eventsource.Store
+func (s *Store) Save(ctx context.Context, aggregateID string, records ...eventsource.Record) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.append(aggregateID, records)
+}
+
+// SaveVersion implements eventsource.VersionedStore
+func (s *Store) SaveVersion(ctx context.Context, aggregateID string, expectedVersion int, records ...eventsource.Record) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	actualVersion := 0
+	if locations := s.byID[aggregateID]; len(locations) > 0 {
+		actualVersion = locations[len(locations)-1].version
+	}
+	if actualVersion != expectedVersion {
+		return &eventsource.ConflictError{
+			AggregateID:     aggregateID,
+			ExpectedVersion: expectedVersion,
+			ActualVersion:   actualVersion,
+		}
+	}
+
+	return s.append(aggregateID, records)
+}
+
+// append writes the records to the active segment as a single frame; callers must hold the lock
+func (s *Store) append(aggregateID string, records []eventsource.Record) error {
+	if s.closed {
+		return errClosed
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	records = sortRecords(records)
+
+	if active := s.active(); active.size >= s.segmentSize {
+		if err := s.roll(); err != nil {
+			return err
+		}
+	}
+
+	seg := s.active()
+	offset := uint64(len(s.records)) + 1
+	frame := encodeFrame(offset, aggregateID, records)
+
+	if _, err := seg.file.WriteAt(frame, seg.size); err != nil {
+		// discard whatever portion of the frame may have been written
+		seg.file.Truncate(seg.size)
+		return fmt.Errorf("unable to write to segment, %v: %v", seg.path, err)
+	}
+
+	if s.syncPolicy == SyncAlways {
+		if err := seg.file.Sync(); err != nil {
+			seg.file.Truncate(seg.size)
+			return fmt.Errorf("unable to sync segment, %v: %v", seg.path, err)
+		}
+	} else {
+		s.dirty = true
+	}
+
+	locations, err := seg.decode(seg.size, frame[headerSize:])
+	if err != nil {
+		return err
+	}
+	for _, loc := range locations {
+		s.index(loc)
+	}
+	seg.size += int64(len(frame))
+
+	s.broadcast()
+
+	return nil
+}
+
+// broadcast wakes any callers waiting on Notify
+func (s *Store) broadcast() {
+	s.notifyMux.Lock()
+	defer s.notifyMux.Unlock()
+
+	if s.notify != nil {
+		close(s.notify)
+		s.notify = nil
+	}
+}
+
+// Notify implements eventsource.StreamNotifier
+func (s *Store) Notify() <-chan struct{} {
+	s.notifyMux.Lock()
+	defer s.notifyMux.Unlock()
+
+	if s.notify == nil {
+		s.notify = make(chan struct{})
+	}
+	return s.notify
+}
+
+// Load implements eventsource.Store
+func (s *Store) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if s.closed {
+		return nil, errClosed
+	}
+
+	locations, ok := s.byID[aggregateID]
+	if !ok {
+		return nil, eventsource.NewNotFoundError(aggregateID)
+	}
+
+	history := make(eventsource.History, 0, len(locations))
+	for _, loc := range locations {
+		if v := loc.version; v < fromVersion || (toVersion > 0 && v > toVersion) {
+			continue
+		}
+
+		record, err := loc.read()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read record from segment, %v: %v", loc.segment.path, err)
+		}
+
+		history = append(history, record)
+	}
+
+	return history, nil
+}
+
+// Read implements eventsource.StreamReader.  Offsets begin at 1
+func (s *Store) Read(ctx context.Context, startingOffset uint64, recordCount int) ([]eventsource.StreamRecord, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if s.closed {
+		return nil, errClosed
+	}
+
+	if startingOffset == 0 {
+		startingOffset = 1
+	}
+
+	var records []eventsource.StreamRecord
+	for i := startingOffset - 1; i < uint64(len(s.records)) && len(records) < recordCount; i++ {
+		loc := s.records[i]
+		record, err := loc.read()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read record from segment, %v: %v", loc.segment.path, err)
+		}
+
+		records = append(records, eventsource.StreamRecord{
+			Record:      record,
+			Offset:      loc.offset,
+			AggregateID: loc.aggregateID,
+		})
+	}
+
+	return records, nil
+}
+
+// Sync flushes the active segment to stable storage
+func (s *Store) Sync() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return errClosed
+	}
+
+	return s.sync()
+}
+
+// sync flushes the active segment if it contains unflushed writes; callers must hold the lock
+func (s *Store) sync() error {
+	if !s.dirty {
+		return nil
+	}
+
+	seg := s.active()
+	if err := seg.file.Sync(); err != nil {
+		return fmt.Errorf("unable to sync segment, %v: %v", seg.path, err)
+	}
+	s.dirty = false
+
+	return nil
+}
+
+func (s *Store) syncLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mux.Lock()
+			if !s.closed {
+				s.sync()
+			}
+			s.mux.Unlock()
+		}
+	}
+}
+
+// Close flushes any outstanding writes and releases the segment files
+func (s *Store) Close() error {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return nil
+	}
+
+	err := s.sync()
+	s.closed = true
+	close(s.done)
+	s.mux.Unlock()
+
+	s.wg.Wait()
+
+	if closeErr := s.closeSegments(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *Store) closeSegments() error {
+	var err error
+	for _, seg := range s.segments {
+		if closeErr := seg.file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+var errClosed = errors.New("filestore: store is closed")
+
+// sortRecords returns the records in version order without modifying the slice provided
+func sortRecords(records []eventsource.Record) []eventsource.Record {
+	history := make(eventsource.History, len(records))
+	copy(history, records)
+	sort.Stable(history)
+	return history
+}
+
+// syncDir flushes the directory entry so newly created segments survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("unable to sync directory, %v: %v", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package filestore_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/filestore"
+	"github.com/eventsource-ecosystem/eventsource/storetest"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "filestore")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	return dir
+}
+
+func open(t *testing.T, dir string, opts ...filestore.Option) *filestore.Store {
+	store, err := filestore.Open(dir, opts...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	return store
+}
+
+func record(version int) eventsource.Record {
+	return eventsource.Record{
+		Version: version,
+		Data:    []byte("data-" + strconv.Itoa(version)),
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) eventsource.Store {
+		dir := tempDir(t)
+		store := open(t, dir, filestore.WithSync(filestore.SyncNever))
+		t.Cleanup(func() {
+			store.Close()
+			os.RemoveAll(dir)
+		})
+		return store
+	})
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	store := open(t, dir)
+
+	if err := store.Save(ctx, "a", record(1), record(2)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Save(ctx, "b", record(1)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.SaveVersion(ctx, "a", 2, record(3)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	verify := func(t *testing.T, store *filestore.Store) {
+		history, err := store.Load(ctx, "a", 2, 3)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := len(history), 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := string(history[1].Data), "data-3"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		records, err := store.Read(ctx, 2, 10)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := len(records), 3; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := records[1].AggregateID, "b"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := records[2].Offset, uint64(4); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+
+	verify(t, store)
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// When the store is reopened, the index should be rebuilt from the segments
+	store = open(t, dir)
+	defer store.Close()
+
+	verify(t, store)
+}
+
+func TestStore_NotFound(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	store := open(t, dir)
+	defer store.Close()
+
+	_, err := store.Load(context.Background(), "does-not-exist", 0, 0)
+	if !eventsource.IsNotFoundError(err) {
+		t.Fatalf("got %v; want not found", err)
+	}
+}
+
+func TestStore_SaveVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	store := open(t, dir)
+	defer store.Close()
+
+	if err := store.SaveVersion(ctx, "a", 0, record(1)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	err := store.SaveVersion(ctx, "a", 0, record(1))
+	if !eventsource.IsConflictError(err) {
+		t.Fatalf("got %v; want conflict", err)
+	}
+}
+
+func TestStore_TornWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	store := open(t, dir, filestore.WithSync(filestore.SyncNever))
+	if err := store.Save(ctx, "a", record(1)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Save(ctx, "a", record(2)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// Simulate a crash part way through writing the second frame
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("got %v, %v; want 1 segment", segments, err)
+	}
+	info, err := os.Stat(segments[0])
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := os.Truncate(segments[0], info.Size()-3); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	store = open(t, dir)
+	defer store.Close()
+
+	history, err := store.Load(ctx, "a", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(history), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// And the store should accept new writes after the truncated frame
+	if err := store.SaveVersion(ctx, "a", 1, record(2)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	records, err := store.Read(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(records), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := string(records[1].Data), "data-2"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_Segments(t *testing.T) {
+	ctx := context.Background()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	store := open(t, dir, filestore.WithSegmentSize(1), filestore.WithSyncInterval(0))
+	for version := 1; version <= 5; version++ {
+		if err := store.Save(ctx, "a", record(version)); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(segments), 5; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	store = open(t, dir)
+	defer store.Close()
+
+	history, err := store.Load(ctx, "a", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(history), 5; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for i, record := range history {
+		if got, want := record.Version, i+1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
+
+func TestStore_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	store := open(t, dir)
+	defer store.Close()
+
+	if err := store.Save(ctx, "a", record(1)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	records, _ := eventsource.Subscribe(ctx, store, 0)
+	if got, want := (<-records).Version, 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if err := store.Save(ctx, "a", record(2)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := (<-records).Version, 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package eventsource
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"golang.org/x/xerrors"
+)
+
+// formatMagic begins every record written by a FormatSerializer so tagged records can be told
+// apart from records written before the FormatSerializer was introduced.  The first byte, 0xF0,
+// does not begin a JSON document, a CBOR or MessagePack array, a protobuf envelope, an Avro
+// single object, or a record written by the compressserializer or encryptserializer packages
+var formatMagic = []byte{0xF0, 'E', 'S', 'F'}
+
+// FormatSerializer composes several Serializers, or formats, so that records written in one
+// format remain readable after switching to another.  Records are written with the primary
+// format and tagged with its name; records are read with the format named by their tag.
+//
+// Records written before the FormatSerializer was introduced carry no tag; use Untagged to
+// specify the format they should be read with.  Migrate rewrites records in the primary format
+// e.g. from a background job that moves a store from one format to another
+type FormatSerializer struct {
+	primary  string
+	formats  map[string]Serializer
+	untagged string
+}
+
+// NewFormatSerializer returns a FormatSerializer that writes records with serializer, tagged
+// with the name, primary.  Register may be subsequently called to read other formats
+func NewFormatSerializer(primary string, serializer Serializer) *FormatSerializer {
+	return &FormatSerializer{
+		primary: primary,
+		formats: map[string]Serializer{primary: serializer},
+	}
+}
+
+// Register adds a format that records may be read with; may be called more than once
+func (f *FormatSerializer) Register(format string, serializer Serializer) {
+	f.formats[format] = serializer
+}
+
+// Untagged specifies the registered format used to read records that carry no format tag
+// e.g. records written by a JSONSerializer prior to the FormatSerializer being introduced
+func (f *FormatSerializer) Untagged(format string) {
+	f.untagged = format
+}
+
+// MarshalEvent implements Serializer using the primary format
+func (f *FormatSerializer) MarshalEvent(event Event) (Record, error) {
+	record, err := f.formats[f.primary].MarshalEvent(event)
+	if err != nil {
+		return Record{}, err
+	}
+
+	data := make([]byte, 0, len(formatMagic)+binary.MaxVarintLen64+len(f.primary)+len(record.Data))
+	data = append(data, formatMagic...)
+	data = binary.AppendUvarint(data, uint64(len(f.primary)))
+	data = append(data, f.primary...)
+	data = append(data, record.Data...)
+	record.Data = data
+
+	return record, nil
+}
+
+// UnmarshalEvent implements Serializer using the format the record was written with
+func (f *FormatSerializer) UnmarshalEvent(record Record) (Event, error) {
+	serializer, record, err := f.serializer(record)
+	if err != nil {
+		return nil, err
+	}
+	return serializer.UnmarshalEvent(record)
+}
+
+// UnmarshalEvents implements EventsUnmarshaler using the format the record was written with
+func (f *FormatSerializer) UnmarshalEvents(record Record) ([]Event, error) {
+	serializer, record, err := f.serializer(record)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalEvents(serializer, record)
+}
+
+// Format returns the name of the format the record was written with.  Returns false if the
+// record carries no format tag
+func (f *FormatSerializer) Format(record Record) (string, bool) {
+	format, _, ok := parseFormat(record.Data)
+	return format, ok
+}
+
+// Migrate rewrites the record in the primary format, preserving its version and metadata.
+// Returns false, along with the original record, if the record is already in the primary
+// format.  Records that unmarshal into more than one event cannot be migrated
+func (f *FormatSerializer) Migrate(record Record) (Record, bool, error) {
+	if format, ok := f.Format(record); ok && format == f.primary {
+		return record, false, nil
+	}
+
+	events, err := f.UnmarshalEvents(record)
+	if err != nil {
+		return Record{}, false, err
+	}
+	if len(events) != 1 {
+		return Record{}, false, xerrors.Errorf("unable to migrate record at version, %v: unmarshaled into %v events: %w", record.Version, len(events), errInvalidEncoding)
+	}
+
+	migrated, err := f.MarshalEvent(events[0])
+	if err != nil {
+		return Record{}, false, err
+	}
+	migrated.Version = record.Version
+	migrated.Metadata = record.Metadata
+
+	return migrated, true, nil
+}
+
+// serializer returns the serializer for the format the record was written with along with the
+// record stripped of its format tag
+func (f *FormatSerializer) serializer(record Record) (Serializer, Record, error) {
+	format, data, ok := parseFormat(record.Data)
+	if !ok {
+		if f.untagged == "" {
+			return nil, Record{}, xerrors.Errorf("record at version, %v, has no format tag and no untagged format was specified: %w", record.Version, errInvalidEncoding)
+		}
+		format, data = f.untagged, record.Data
+	}
+
+	serializer, ok := f.formats[format]
+	if !ok {
+		return nil, Record{}, xerrors.Errorf("record at version, %v, has unregistered format, %v: %w", record.Version, format, errInvalidEncoding)
+	}
+
+	record.Data = data
+	return serializer, record, nil
+}
+
+// parseFormat splits data into the format name and the record data written by that format.
+// Returns false if data carries no format tag
+func parseFormat(data []byte) (string, []byte, bool) {
+	if !bytes.HasPrefix(data, formatMagic) {
+		return "", nil, false
+	}
+
+	n, size := binary.Uvarint(data[len(formatMagic):])
+	if size <= 0 || uint64(len(data)-len(formatMagic)-size) < n {
+		return "", nil, false
+	}
+	offset := len(formatMagic) + size
+	return string(data[offset : offset+int(n)]), data[offset+int(n):], true
+}
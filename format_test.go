@@ -0,0 +1,136 @@
+package eventsource_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/encryptserializer"
+)
+
+// newEncrypted returns an encrypted JSON serializer to use as a second format
+func newEncrypted(t *testing.T, events ...eventsource.Event) eventsource.Serializer {
+	keys, err := encryptserializer.NewKeyRing("2020-01", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	return encryptserializer.New(eventsource.NewJSONSerializer(events...), keys)
+}
+
+func TestFormatSerializer(t *testing.T) {
+	ctx := context.Background()
+	id := "abc"
+	store := eventsource.NewMemoryStore()
+
+	// records written before the migration carry no format tag
+	jsonSerializer := eventsource.NewJSONSerializer(EntityCreated{}, EntityNameSet{})
+	before := eventsource.New(&Entity{},
+		eventsource.WithStore(store),
+		eventsource.WithSerializer(jsonSerializer),
+	)
+	err := before.Save(ctx, &EntityCreated{
+		Model: eventsource.Model{ID: id, Version: 1, At: time.Unix(3, 0)},
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	serializer := eventsource.NewFormatSerializer("encrypted", newEncrypted(t, EntityCreated{}, EntityNameSet{}))
+	serializer.Register("json", jsonSerializer)
+	serializer.Untagged("json")
+
+	after := eventsource.New(&Entity{},
+		eventsource.WithStore(store),
+		eventsource.WithSerializer(serializer),
+	)
+	err = after.Save(eventsource.ContextWithActor(ctx, "alice"), &EntityNameSet{
+		Model: eventsource.Model{ID: id, Version: 2, At: time.Unix(4, 0)},
+		Name:  "Jones",
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	v, version, err := after.Load(ctx, id)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := version, 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := v.(*Entity).Name, "Jones"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	history, err := store.Load(ctx, id, 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if _, ok := serializer.Format(history[0]); ok {
+		t.Fatalf("got true; want false")
+	}
+	if got, ok := serializer.Format(history[1]); !ok || got != "encrypted" {
+		t.Fatalf("got %v, %v; want encrypted, true", got, ok)
+	}
+
+	// rewrite the history in the primary format
+	for i, record := range history {
+		migrated, ok, err := serializer.Migrate(record)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := ok, i == 0; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := migrated.Version, record.Version; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := migrated.Metadata[eventsource.MetadataEventID], record.Metadata[eventsource.MetadataEventID]; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, ok := serializer.Format(migrated); !ok || got != "encrypted" {
+			t.Fatalf("got %v, %v; want encrypted, true", got, ok)
+		}
+
+		event, err := serializer.UnmarshalEvent(migrated)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := event.EventVersion(), record.Version; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
+
+func TestFormatSerializer_Errors(t *testing.T) {
+	event := &EntityCreated{Model: eventsource.Model{ID: "abc", Version: 1}}
+
+	untagged, err := eventsource.NewJSONSerializer(EntityCreated{}).MarshalEvent(event)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	serializer := eventsource.NewFormatSerializer("encrypted", newEncrypted(t, EntityCreated{}))
+	if _, err := serializer.UnmarshalEvent(untagged); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+
+	other := eventsource.NewFormatSerializer("json", eventsource.NewJSONSerializer(EntityCreated{}))
+	record, err := other.MarshalEvent(event)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if _, err := serializer.UnmarshalEvent(record); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	if _, _, err := serializer.Migrate(record); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+
+	serializer.Register("json", eventsource.NewJSONSerializer())
+	if _, err := serializer.UnmarshalEvent(record); !eventsource.IsUnboundEventTypeError(err) {
+		t.Fatalf("got %v; want error detectable with IsUnboundEventTypeError", err)
+	}
+}
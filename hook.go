@@ -0,0 +1,65 @@
+package eventsource
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+)
+
+// Hooks attach cross cutting behaviour, such as auditing or cache invalidation, to the
+// Repository.  Each func is optional
+type Hooks struct {
+	// BeforeSave is called by Save and Apply before events are persisted; returning an error
+	// aborts the save and the error is returned to the caller
+	BeforeSave func(ctx context.Context, aggregateID string, events []Event) error
+
+	// AfterSave is called by Save and Apply once events have been persisted.  version contains
+	// the version of the last event saved
+	AfterSave func(ctx context.Context, aggregateID string, version int, events []Event)
+
+	// AfterLoad is called by Load, LoadVersion, LoadAt and Apply once the aggregate has been
+	// loaded; returning an error fails the load
+	AfterLoad func(ctx context.Context, aggregateID string, aggregate Aggregate, version int) error
+}
+
+// WithHooks registers hooks with the Repository; may be provided more than once in which case
+// hooks are called in the order they were registered
+func WithHooks(hooks Hooks) Option {
+	return func(r *Repository) {
+		r.hooks = append(r.hooks, hooks)
+	}
+}
+
+func (r *Repository) beforeSave(ctx context.Context, aggregateID string, events []Event) error {
+	for _, hooks := range r.hooks {
+		if hooks.BeforeSave == nil {
+			continue
+		}
+		if err := hooks.BeforeSave(ctx, aggregateID, events); err != nil {
+			return xerrors.Errorf("save of aggregate, %v, rejected by hook: %w", aggregateID, err)
+		}
+	}
+	return nil
+}
+
+// afterSave calls the AfterSave hooks followed by the observers
+func (r *Repository) afterSave(ctx context.Context, aggregateID string, version int, events []Event, records []Record) {
+	for _, hooks := range r.hooks {
+		if hooks.AfterSave != nil {
+			hooks.AfterSave(ctx, aggregateID, version, events)
+		}
+	}
+	r.notify(ctx, aggregateID, version, events, records)
+}
+
+func (r *Repository) afterLoad(ctx context.Context, aggregateID string, aggregate Aggregate, version int) error {
+	for _, hooks := range r.hooks {
+		if hooks.AfterLoad == nil {
+			continue
+		}
+		if err := hooks.AfterLoad(ctx, aggregateID, aggregate, version); err != nil {
+			return xerrors.Errorf("load of aggregate, %v, rejected by hook: %w", aggregateID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package eventsource_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestWithHooks(t *testing.T) {
+	ctx := context.Background()
+
+	var calls []string
+	var observed []string
+	repository := eventsource.New(&Entity{},
+		eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{}, EntityNameSet{})),
+		eventsource.WithHooks(eventsource.Hooks{
+			BeforeSave: func(ctx context.Context, aggregateID string, events []eventsource.Event) error {
+				calls = append(calls, "before-save:"+aggregateID)
+				return nil
+			},
+			AfterSave: func(ctx context.Context, aggregateID string, version int, events []eventsource.Event) {
+				calls = append(calls, "after-save:"+aggregateID)
+			},
+			AfterLoad: func(ctx context.Context, aggregateID string, aggregate eventsource.Aggregate, version int) error {
+				calls = append(calls, "after-load:"+aggregateID)
+				return nil
+			},
+		}),
+		eventsource.WithObservers(func(event eventsource.Event) {
+			observed = append(observed, event.AggregateID())
+		}),
+	)
+
+	// Save, as used to seed or import events, notifies hooks and observers
+	err := repository.Save(ctx,
+		&EntityCreated{Model: eventsource.Model{ID: "a", Version: 1, At: time.Now()}},
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if _, err := repository.Apply(ctx, &CreateEntity{CommandModel: eventsource.CommandModel{ID: "a"}}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if _, _, err := repository.Load(ctx, "a"); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []string{
+		"before-save:a", "after-save:a", // Save
+		"after-load:a", "before-save:a", "after-save:a", // Apply
+		"after-load:a", // Load
+	}
+	if got := calls; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := observed, []string{"a", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestWithHooks_Reject(t *testing.T) {
+	ctx := context.Background()
+	rejected := errors.New("rejected")
+
+	repository := eventsource.New(&Entity{},
+		eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{})),
+		eventsource.WithHooks(eventsource.Hooks{
+			BeforeSave: func(ctx context.Context, aggregateID string, events []eventsource.Event) error {
+				if aggregateID == "blocked" {
+					return rejected
+				}
+				return nil
+			},
+			AfterLoad: func(ctx context.Context, aggregateID string, aggregate eventsource.Aggregate, version int) error {
+				if aggregateID == "hidden" {
+					return rejected
+				}
+				return nil
+			},
+		}),
+	)
+
+	_, err := repository.Apply(ctx, &CreateEntity{CommandModel: eventsource.CommandModel{ID: "blocked"}})
+	if !errors.Is(err, rejected) {
+		t.Fatalf("got %v; want %v", err, rejected)
+	}
+	if _, _, err := repository.Load(ctx, "blocked"); !eventsource.IsNotFoundError(err) {
+		t.Fatalf("got %v; want not found", err)
+	}
+
+	if _, err := repository.Apply(ctx, &CreateEntity{CommandModel: eventsource.CommandModel{ID: "hidden"}}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if _, _, err := repository.Load(ctx, "hidden"); !errors.Is(err, rejected) {
+		t.Fatalf("got %v; want %v", err, rejected)
+	}
+	if _, err := repository.Apply(ctx, &CreateEntity{CommandModel: eventsource.CommandModel{ID: "hidden"}}); !errors.Is(err, rejected) {
+		t.Fatalf("got %v; want %v", err, rejected)
+	}
+}
@@ -0,0 +1,115 @@
+// Package codecserializer implements the envelope and event type registry shared by the
+// serializers that encode events with a general purpose codec such as CBOR or MessagePack.
+//
+// Each record contains a two element array, encoded with the codec, holding the event type and
+// the encoded event as a byte string.
+package codecserializer
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// MarshalFunc encodes v using the codec
+type MarshalFunc func(v interface{}) ([]byte, error)
+
+// UnmarshalFunc decodes data into v using the codec
+type UnmarshalFunc func(data []byte, v interface{}) error
+
+// Serializer converts between Events and Records encoded with the codec
+type Serializer struct {
+	marshal    MarshalFunc
+	unmarshal  UnmarshalFunc
+	eventTypes map[string]reflect.Type
+}
+
+// Bind registers the specified events with the serializer; may be called more than once
+func (s *Serializer) Bind(events ...eventsource.Event) {
+	for _, event := range events {
+		eventType, t := eventsource.EventType(event)
+		s.eventTypes[eventType] = t
+	}
+}
+
+// MarshalEvent implements eventsource.Serializer
+func (s *Serializer) MarshalEvent(event eventsource.Event) (eventsource.Record, error) {
+	eventType, _ := eventsource.EventType(event)
+
+	data, err := s.marshal(event)
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to marshal event, %v: %v", eventType, err)
+	}
+
+	data, err = s.marshal([]interface{}{eventType, data})
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to marshal envelope of event, %v: %v", eventType, err)
+	}
+
+	return eventsource.Record{
+		Version: event.EventVersion(),
+		Data:    data,
+	}, nil
+}
+
+// UnmarshalEvent implements eventsource.Serializer.  Returns an error detectable with
+// eventsource.IsUnboundEventTypeError if the event type has not been bound
+func (s *Serializer) UnmarshalEvent(record eventsource.Record) (eventsource.Event, error) {
+	var envelope []interface{}
+	if err := s.unmarshal(record.Data, &envelope); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal envelope of record at version, %v: %v", record.Version, err)
+	}
+
+	var eventType string
+	var data []byte
+	ok := len(envelope) == 2
+	if ok {
+		eventType, ok = envelope[0].(string)
+	}
+	if ok {
+		data, ok = envelope[1].([]byte)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unable to unmarshal envelope of record at version, %v: want event type and data", record.Version)
+	}
+
+	t, ok := s.eventTypes[eventType]
+	if !ok {
+		return nil, eventsource.NewUnboundEventTypeError(eventType)
+	}
+
+	v := reflect.New(t).Interface()
+	if err := s.unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal event, %v: %v", eventType, err)
+	}
+
+	return v.(eventsource.Event), nil
+}
+
+// MarshalAll is a utility that marshals all the events provided into a History object
+func (s *Serializer) MarshalAll(events ...eventsource.Event) (eventsource.History, error) {
+	history := make(eventsource.History, 0, len(events))
+	for _, event := range events {
+		record, err := s.MarshalEvent(event)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, record)
+	}
+	return history, nil
+}
+
+// New constructs a new Serializer that encodes records with the codec, marshal and unmarshal,
+// and populates it with the specified events.  Bind may be subsequently called to add more
+// events
+func New(marshal MarshalFunc, unmarshal UnmarshalFunc, events ...eventsource.Event) *Serializer {
+	s := &Serializer{
+		marshal:    marshal,
+		unmarshal:  unmarshal,
+		eventTypes: map[string]reflect.Type{},
+	}
+	s.Bind(events...)
+
+	return s
+}
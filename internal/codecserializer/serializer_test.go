@@ -0,0 +1,90 @@
+package codecserializer_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/internal/codecserializer"
+)
+
+type EntityCreated struct {
+	eventsource.Model
+	Name string
+}
+
+type EntityRenamed struct {
+	eventsource.Model
+	Name string
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func TestSerializer(t *testing.T) {
+	serializer := codecserializer.New(marshal, unmarshal, EntityCreated{})
+	serializer.Bind(EntityRenamed{})
+
+	events := []eventsource.Event{
+		&EntityCreated{Model: eventsource.Model{ID: "abc", Version: 1}, Name: "a"},
+		&EntityRenamed{Model: eventsource.Model{ID: "abc", Version: 2}, Name: "b"},
+	}
+
+	history, err := serializer.MarshalAll(events...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(history), len(events); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	for i, record := range history {
+		if got, want := record.Version, i+1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		event, err := serializer.UnmarshalEvent(record)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := event, events[i]; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v; want %#v", got, want)
+		}
+	}
+}
+
+func TestSerializer_Errors(t *testing.T) {
+	serializer := codecserializer.New(marshal, unmarshal)
+
+	record, err := serializer.MarshalEvent(EntityCreated{Model: eventsource.Model{ID: "abc", Version: 1}})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if _, err := serializer.UnmarshalEvent(record); !eventsource.IsUnboundEventTypeError(err) {
+		t.Fatalf("got %v; want error detectable with IsUnboundEventTypeError", err)
+	}
+
+	if _, err := serializer.UnmarshalEvent(eventsource.Record{Data: []byte("{}")}); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+
+	// envelope that is not an event type followed by data
+	data, err := marshal([]interface{}{"EntityCreated"})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if _, err := serializer.UnmarshalEvent(eventsource.Record{Data: data}); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
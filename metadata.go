@@ -0,0 +1,114 @@
+package eventsource
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Well known metadata keys.  Metadata may contain any other keys e.g. request ids or tracing
+// headers
+const (
+	// MetadataEventID uniquely identifies each record saved by the Repository
+	MetadataEventID = "event_id"
+
+	// MetadataCorrelationID identifies the originating request; it is shared by every record
+	// caused, directly or indirectly, by that request
+	MetadataCorrelationID = "correlation_id"
+
+	// MetadataCausationID contains the event id of the record that caused this record
+	MetadataCausationID = "causation_id"
+
+	// MetadataActor identifies who, or what, issued the command
+	MetadataActor = "actor"
+)
+
+type metadataKey struct{}
+
+// ContextWithMetadata returns a copy of ctx carrying md merged over any metadata already
+// attached to ctx.  Records saved by Repository.Apply and Repository.Save include the metadata
+// attached to the context they were called with
+func ContextWithMetadata(ctx context.Context, md map[string]string) context.Context {
+	merged := map[string]string{}
+	for k, v := range MetadataFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
+
+// ContextWithActor is shorthand for attaching MetadataActor to ctx
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return ContextWithMetadata(ctx, map[string]string{MetadataActor: actor})
+}
+
+// ContextWithCause returns a copy of ctx carrying the metadata of the record being handled, so
+// that records saved while handling it are linked to it.  The event id of the cause becomes
+// the causation id and the correlation id is carried over, defaulting to the event id of the
+// cause.  Other keys, such as the actor, are carried over as is
+func ContextWithCause(ctx context.Context, cause map[string]string) context.Context {
+	if len(cause) == 0 {
+		return ctx
+	}
+
+	md := map[string]string{}
+	for k, v := range cause {
+		md[k] = v
+	}
+	delete(md, MetadataEventID)
+
+	if eventID, ok := cause[MetadataEventID]; ok {
+		md[MetadataCausationID] = eventID
+		if _, ok := md[MetadataCorrelationID]; !ok {
+			md[MetadataCorrelationID] = eventID
+		}
+	}
+
+	return ContextWithMetadata(ctx, md)
+}
+
+// MetadataFromContext returns the metadata attached to ctx or nil if none has been attached.
+// The returned map must not be modified
+func MetadataFromContext(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return md
+}
+
+// newEventID returns a random, hex encoded, 128 bit identifier
+func newEventID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// withMetadata attaches the metadata from ctx to each record along with a unique event id.
+// When ctx carries no correlation id, the event id of the first record is used so the records
+// of a command are always correlated
+func withMetadata(ctx context.Context, records []Record) {
+	md := MetadataFromContext(ctx)
+	var correlationID string
+	for i := range records {
+		metadata := make(map[string]string, len(md)+len(records[i].Metadata)+2)
+		for k, v := range md {
+			metadata[k] = v
+		}
+		for k, v := range records[i].Metadata {
+			metadata[k] = v
+		}
+
+		eventID := newEventID()
+		metadata[MetadataEventID] = eventID
+		if _, ok := metadata[MetadataCorrelationID]; !ok {
+			if correlationID == "" {
+				correlationID = eventID
+			}
+			metadata[MetadataCorrelationID] = correlationID
+		}
+
+		records[i].Metadata = metadata
+	}
+}
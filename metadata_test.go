@@ -0,0 +1,126 @@
+package eventsource_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestContextWithMetadata(t *testing.T) {
+	ctx := context.Background()
+	if got := eventsource.MetadataFromContext(ctx); got != nil {
+		t.Fatalf("got %v; want nil", got)
+	}
+
+	ctx = eventsource.ContextWithMetadata(ctx, map[string]string{"request_id": "abc"})
+	ctx = eventsource.ContextWithActor(ctx, "alice")
+
+	md := eventsource.MetadataFromContext(ctx)
+	if got, want := md["request_id"], "abc"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := md[eventsource.MetadataActor], "alice"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestContextWithCause(t *testing.T) {
+	cause := map[string]string{
+		eventsource.MetadataEventID: "1",
+		eventsource.MetadataActor:   "alice",
+	}
+
+	md := eventsource.MetadataFromContext(eventsource.ContextWithCause(context.Background(), cause))
+	if got, want := md[eventsource.MetadataCausationID], "1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := md[eventsource.MetadataCorrelationID], "1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := md[eventsource.MetadataActor], "alice"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if _, ok := md[eventsource.MetadataEventID]; ok {
+		t.Fatalf("got event id; want event id of cause not to be carried over")
+	}
+
+	// an existing correlation id is carried over
+	cause[eventsource.MetadataCorrelationID] = "request"
+	md = eventsource.MetadataFromContext(eventsource.ContextWithCause(context.Background(), cause))
+	if got, want := md[eventsource.MetadataCorrelationID], "request"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestRepository_Metadata(t *testing.T) {
+	var notifications []eventsource.Notification
+	observer := eventsource.ObserverFunc(func(ctx context.Context, notification eventsource.Notification) error {
+		notifications = append(notifications, notification)
+		return nil
+	})
+
+	store := eventsource.NewMemoryStore()
+	repository := eventsource.New(&Entity{},
+		eventsource.WithStore(store),
+		eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{}, EntityNameSet{})),
+		eventsource.WithObserver(observer),
+	)
+
+	ctx := eventsource.ContextWithActor(context.Background(), "alice")
+	err := repository.Save(ctx,
+		&EntityCreated{Model: eventsource.Model{ID: "abc", Version: 1}},
+		&EntityNameSet{Model: eventsource.Model{ID: "abc", Version: 2}, Name: "name"},
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	history, err := store.Load(ctx, "abc", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(history), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	first, second := history[0].Metadata, history[1].Metadata
+	if got, want := first[eventsource.MetadataActor], "alice"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if first[eventsource.MetadataEventID] == "" || first[eventsource.MetadataEventID] == second[eventsource.MetadataEventID] {
+		t.Fatalf("got %v and %v; want unique event ids", first[eventsource.MetadataEventID], second[eventsource.MetadataEventID])
+	}
+
+	// records saved together share the correlation id
+	if got, want := second[eventsource.MetadataCorrelationID], first[eventsource.MetadataEventID]; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if got, want := len(notifications), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := notifications[1].Metadata[eventsource.MetadataEventID], second[eventsource.MetadataEventID]; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// commands applied on behalf of an event are caused by it
+	ctx = eventsource.ContextWithCause(context.Background(), second)
+	if _, err := repository.Apply(ctx, &CreateEntity{CommandModel: eventsource.CommandModel{ID: "def"}}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	history, err = store.Load(ctx, "def", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	md := history[0].Metadata
+	if got, want := md[eventsource.MetadataCausationID], second[eventsource.MetadataEventID]; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := md[eventsource.MetadataCorrelationID], first[eventsource.MetadataEventID]; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := md[eventsource.MetadataActor], "alice"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
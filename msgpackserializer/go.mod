@@ -0,0 +1,14 @@
+module github.com/eventsource-ecosystem/eventsource/msgpackserializer
+
+go 1.21
+
+require github.com/eventsource-ecosystem/eventsource v0.0.0-00010101000000-000000000000
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+
+require (
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7 // indirect
+)
+
+replace github.com/eventsource-ecosystem/eventsource => ..
@@ -0,0 +1,46 @@
+// Package msgpackserializer provides an eventsource.Serializer that encodes events as
+// MessagePack, a compact binary alternative to eventsource.JSONSerializer.
+//
+// Each record contains a two element MessagePack array holding the event type and the encoded
+// event.  Fields are encoded by name, so the msgpack struct tag may be used to shorten them.
+package msgpackserializer
+
+import (
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/internal/codecserializer"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Serializer converts between Events and MessagePack encoded Records
+type Serializer struct {
+	codec *codecserializer.Serializer
+}
+
+// Bind registers the specified events with the serializer; may be called more than once
+func (s *Serializer) Bind(events ...eventsource.Event) {
+	s.codec.Bind(events...)
+}
+
+// MarshalEvent implements eventsource.Serializer
+func (s *Serializer) MarshalEvent(event eventsource.Event) (eventsource.Record, error) {
+	return s.codec.MarshalEvent(event)
+}
+
+// UnmarshalEvent implements eventsource.Serializer.  Returns an error detectable with
+// eventsource.IsUnboundEventTypeError if the event type has not been bound
+func (s *Serializer) UnmarshalEvent(record eventsource.Record) (eventsource.Event, error) {
+	return s.codec.UnmarshalEvent(record)
+}
+
+// MarshalAll is a utility that marshals all the events provided into a History object
+func (s *Serializer) MarshalAll(events ...eventsource.Event) (eventsource.History, error) {
+	return s.codec.MarshalAll(events...)
+}
+
+// New constructs a new Serializer and populates it with the specified events.  Bind may be
+// subsequently called to add more events
+func New(events ...eventsource.Event) *Serializer {
+	return &Serializer{
+		codec: codecserializer.New(msgpack.Marshal, msgpack.Unmarshal, events...),
+	}
+}
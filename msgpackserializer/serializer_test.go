@@ -0,0 +1,97 @@
+package msgpackserializer_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/msgpackserializer"
+)
+
+type Address struct {
+	Street string
+	City   string
+}
+
+type OrderPlaced struct {
+	eventsource.Model
+	Amount   int64
+	Items    []string
+	Tags     map[string]string
+	Shipping *Address
+}
+
+type OrderShipped struct {
+	eventsource.Model
+	Carrier string
+}
+
+// withoutAt clears the time of the event as decoded times differ in location from the original
+func withoutAt(event eventsource.Event) eventsource.Event {
+	reflect.ValueOf(event).Elem().FieldByName("At").Set(reflect.ValueOf(time.Time{}))
+	return event
+}
+
+func TestSerializer(t *testing.T) {
+	serializer := msgpackserializer.New(OrderPlaced{})
+	serializer.Bind(OrderShipped{})
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 6789, time.UTC)
+	events := []eventsource.Event{
+		&OrderPlaced{
+			Model:    eventsource.Model{ID: "abc", Version: 1, At: at},
+			Amount:   100,
+			Items:    []string{"a", "b"},
+			Tags:     map[string]string{"k": "v"},
+			Shipping: &Address{Street: "Queen St", City: "Auckland"},
+		},
+		&OrderShipped{
+			Model:   eventsource.Model{ID: "abc", Version: 2, At: at},
+			Carrier: "post",
+		},
+	}
+
+	history, err := serializer.MarshalAll(events...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	for i, record := range history {
+		if got, want := record.Version, i+1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		event, err := serializer.UnmarshalEvent(record)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := event.EventAt(), at; !got.Equal(want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := withoutAt(event), withoutAt(events[i]); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v; want %#v", got, want)
+		}
+	}
+}
+
+func TestSerializer_Size(t *testing.T) {
+	event := OrderPlaced{
+		Model:  eventsource.Model{ID: "abc", Version: 1, At: time.Now()},
+		Amount: 100,
+		Items:  []string{"a", "b"},
+	}
+
+	record, err := msgpackserializer.New(event).MarshalEvent(event)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	jsonRecord, err := eventsource.NewJSONSerializer(event).MarshalEvent(event)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if got, want := len(record.Data), len(jsonRecord.Data); got >= want {
+		t.Fatalf("got %v; want less than %v", got, want)
+	}
+}
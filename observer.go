@@ -0,0 +1,242 @@
+package eventsource
+
+import (
+	"context"
+	"sync"
+)
+
+// Notification describes an event saved by the Repository
+type Notification struct {
+	// AggregateID identifies the aggregate the event was applied to
+	AggregateID string
+
+	// Version contains the version of the aggregate once all the events from the same command
+	// were saved
+	Version int
+
+	// EventType contains the type of the event as returned by EventType
+	EventType string
+
+	// Event contains the event that was saved
+	Event Event
+
+	// Metadata contains the metadata saved with the event
+	Metadata map[string]string
+}
+
+// Observer is notified of each event saved by Repository.Save or Repository.Apply
+type Observer interface {
+	// Observe is called once for each event saved.  Errors are reported to the func provided
+	// to WithObserverErrorHandler; the events have already been saved
+	Observe(ctx context.Context, notification Notification) error
+}
+
+// ObserverFunc provides a func alternative for declaring an Observer
+type ObserverFunc func(ctx context.Context, notification Notification) error
+
+// Observe implements the Observer interface
+func (fn ObserverFunc) Observe(ctx context.Context, notification Notification) error {
+	return fn(ctx, notification)
+}
+
+// ObserverErrorHandler receives the errors returned by observers along with the notification
+// being observed
+type ObserverErrorHandler func(ctx context.Context, notification Notification, err error)
+
+// OverflowPolicy determines what happens to notifications that arrive when the async observer
+// queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks Repository.Save or Repository.Apply until there is room in the queue
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest discards the notification that did not fit
+	OverflowDropNewest
+
+	// OverflowDropOldest discards the oldest queued notification to make room
+	OverflowDropOldest
+)
+
+// WithObserver registers observers that are notified of each event saved by Save or Apply.
+// By default observers are called synchronously, in order, before Save or Apply returns; see
+// WithAsyncObservers
+func WithObserver(observers ...Observer) Option {
+	return func(r *Repository) {
+		r.observers = append(r.observers, observers...)
+	}
+}
+
+// WithObserverErrorHandler specifies the func that receives observer errors along with
+// notifications dropped by the async queue; by default errors are logged when WithDebug
+// is set
+func WithObserverErrorHandler(fn ObserverErrorHandler) Option {
+	return func(r *Repository) {
+		r.observerErrors = fn
+	}
+}
+
+// WithAsyncObservers delivers notifications to observers from a background goroutine via a
+// queue holding up to size notifications.  overflow determines what happens when the queue is
+// full; dropped notifications are reported to the ObserverErrorHandler with an error
+// detectable with IsObserverOverflowError.  Use Repository.Close to drain the queue
+func WithAsyncObservers(size int, overflow OverflowPolicy) Option {
+	return func(r *Repository) {
+		if size <= 0 {
+			size = 1
+		}
+		r.queue = &observerQueue{
+			size:     size,
+			overflow: overflow,
+		}
+	}
+}
+
+// queuedNotification holds a notification along with the context it was created in
+type queuedNotification struct {
+	ctx          context.Context
+	notification Notification
+}
+
+// observerQueue provides the bounded queue used by WithAsyncObservers
+type observerQueue struct {
+	size     int
+	overflow OverflowPolicy
+
+	once   sync.Once
+	mux    sync.Mutex
+	ch     chan queuedNotification
+	closed bool
+	done   chan struct{}
+}
+
+// notify delivers the notifications for the saved events to the observers
+func (r *Repository) notify(ctx context.Context, aggregateID string, version int, events []Event, records []Record) {
+	if len(r.observers) == 0 {
+		return
+	}
+
+	for i, event := range events {
+		eventType, _ := EventType(event)
+		notification := Notification{
+			AggregateID: aggregateID,
+			Version:     version,
+			EventType:   eventType,
+			Event:       event,
+			Metadata:    records[i].Metadata,
+		}
+
+		if r.queue == nil || !r.enqueue(ctx, notification) {
+			r.observe(ctx, notification)
+		}
+	}
+}
+
+// observe calls each observer in turn, reporting errors to the ObserverErrorHandler
+func (r *Repository) observe(ctx context.Context, notification Notification) {
+	for _, observer := range r.observers {
+		if err := observer.Observe(ctx, notification); err != nil {
+			r.observerError(ctx, notification, err)
+		}
+	}
+}
+
+func (r *Repository) observerError(ctx context.Context, notification Notification, err error) {
+	if r.observerErrors != nil {
+		r.observerErrors(ctx, notification, err)
+		return
+	}
+	r.logf("observer failed for %v event on aggregate, %v: %v", notification.EventType, notification.AggregateID, err)
+}
+
+// enqueue adds the notification to the async queue.  Returns false if the queue has been
+// closed, in which case the caller should deliver the notification synchronously
+func (r *Repository) enqueue(ctx context.Context, notification Notification) bool {
+	q := r.queue
+	q.once.Do(func() {
+		q.ch = make(chan queuedNotification, q.size)
+		q.done = make(chan struct{})
+		go r.drain()
+	})
+
+	// async observers outlive the call to Save or Apply so should not be cancelled with it
+	item := queuedNotification{ctx: context.WithoutCancel(ctx), notification: notification}
+
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	for {
+		select {
+		case q.ch <- item:
+			return true
+		default:
+		}
+
+		switch q.overflow {
+		case OverflowDropNewest:
+			r.observerError(ctx, notification, errObserverOverflow)
+			return true
+
+		case OverflowDropOldest:
+			select {
+			case dropped := <-q.ch:
+				r.observerError(dropped.ctx, dropped.notification, errObserverOverflow)
+			default:
+			}
+
+		default:
+			// OverflowBlock; the lock is held so Close cannot close the channel mid send
+			select {
+			case q.ch <- item:
+				return true
+			case <-ctx.Done():
+				r.observerError(ctx, notification, errObserverOverflow)
+				return true
+			}
+		}
+	}
+}
+
+// drain delivers queued notifications until the queue is closed
+func (r *Repository) drain() {
+	defer close(r.queue.done)
+
+	for item := range r.queue.ch {
+		r.observe(item.ctx, item.notification)
+	}
+}
+
+// Close waits for the notifications queued by WithAsyncObservers to be delivered.
+// Notifications for events saved after Close are delivered synchronously.  Close returns
+// ctx.Err() if ctx is done before the queue has drained
+func (r *Repository) Close(ctx context.Context) error {
+	q := r.queue
+	if q == nil {
+		return nil
+	}
+
+	q.once.Do(func() {
+		q.done = make(chan struct{})
+		close(q.done)
+	})
+
+	q.mux.Lock()
+	if !q.closed {
+		q.closed = true
+		if q.ch != nil {
+			close(q.ch)
+		}
+	}
+	q.mux.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
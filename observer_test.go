@@ -0,0 +1,174 @@
+package eventsource_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+type contextKey string
+
+func TestWithObserver(t *testing.T) {
+	var captured []eventsource.Notification
+	observer := eventsource.ObserverFunc(func(ctx context.Context, notification eventsource.Notification) error {
+		if got, want := ctx.Value(contextKey("key")), "value"; got != want {
+			t.Errorf("got %v; want %v", got, want)
+		}
+		captured = append(captured, notification)
+		return errors.New("boom")
+	})
+
+	var reported []error
+	repository := eventsource.New(&Entity{},
+		eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{})),
+		eventsource.WithObserver(observer),
+		eventsource.WithObserverErrorHandler(func(ctx context.Context, notification eventsource.Notification, err error) {
+			reported = append(reported, err)
+		}),
+	)
+
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	if _, err := repository.Apply(ctx, &CreateEntity{CommandModel: eventsource.CommandModel{ID: "abc"}}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if got, want := len(captured), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	notification := captured[0]
+	if got, want := notification.AggregateID, "abc"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := notification.Version, 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := notification.EventType, "EntityCreated"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := len(reported), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestWithAsyncObservers(t *testing.T) {
+	var mux sync.Mutex
+	var captured []eventsource.Notification
+	observer := eventsource.ObserverFunc(func(ctx context.Context, notification eventsource.Notification) error {
+		mux.Lock()
+		defer mux.Unlock()
+
+		captured = append(captured, notification)
+		return nil
+	})
+
+	repository := eventsource.New(&Entity{},
+		eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{})),
+		eventsource.WithObserver(observer),
+		eventsource.WithAsyncObservers(16, eventsource.OverflowBlock),
+	)
+
+	// observers should not be affected by the cancellation of the context passed to Apply
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := repository.Apply(ctx, &CreateEntity{CommandModel: eventsource.CommandModel{ID: id}}); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+	cancel()
+
+	if err := repository.Close(context.Background()); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	mux.Lock()
+	if got, want := len(captured), 3; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for i, id := range []string{"a", "b", "c"} {
+		if got, want := captured[i].AggregateID, id; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+	mux.Unlock()
+
+	// once closed, observers are called synchronously
+	if _, err := repository.Apply(context.Background(), &CreateEntity{CommandModel: eventsource.CommandModel{ID: "d"}}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if got, want := len(captured), 4; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestWithAsyncObservers_Overflow(t *testing.T) {
+	testCases := map[string]struct {
+		Overflow eventsource.OverflowPolicy
+		Dropped  string
+	}{
+		"drop newest": {Overflow: eventsource.OverflowDropNewest, Dropped: "c"},
+		"drop oldest": {Overflow: eventsource.OverflowDropOldest, Dropped: "b"},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			// block the observer on the first notification so the queue fills
+			started := make(chan struct{})
+			release := make(chan struct{})
+			var once sync.Once
+			observer := eventsource.ObserverFunc(func(ctx context.Context, notification eventsource.Notification) error {
+				once.Do(func() { close(started) })
+				<-release
+				return nil
+			})
+
+			var mux sync.Mutex
+			var dropped []string
+			repository := eventsource.New(&Entity{},
+				eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{})),
+				eventsource.WithDebug(io.Discard),
+				eventsource.WithObserver(observer),
+				eventsource.WithAsyncObservers(1, tc.Overflow),
+				eventsource.WithObserverErrorHandler(func(ctx context.Context, notification eventsource.Notification, err error) {
+					if !eventsource.IsObserverOverflowError(err) {
+						t.Errorf("got %v; want overflow error", err)
+					}
+					mux.Lock()
+					dropped = append(dropped, notification.AggregateID)
+					mux.Unlock()
+				}),
+			)
+
+			ctx := context.Background()
+			apply := func(id string) {
+				if _, err := repository.Apply(ctx, &CreateEntity{CommandModel: eventsource.CommandModel{ID: id}}); err != nil {
+					t.Fatalf("got %v; want nil", err)
+				}
+			}
+
+			apply("a")
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for observer")
+			}
+			apply("b") // fills the queue
+			apply("c") // overflows
+
+			close(release)
+			if err := repository.Close(ctx); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+
+			if got, want := dropped, []string{tc.Dropped}; len(got) != 1 || got[0] != want[0] {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
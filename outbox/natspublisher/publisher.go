@@ -0,0 +1,149 @@
+// Package natspublisher provides an outbox.Publisher that sends records to a NATS JetStream
+// stream.
+//
+// Each record is published as a message whose body contains the record's data.  The offset,
+// aggregate id, version and metadata are carried in headers; Decode reverses the mapping.  The
+// Nats-Msg-Id header identifies the record, by default as <aggregate id>/<version>, so JetStream
+// streams discard the duplicates produced by the outbox's at-least-once delivery.  Use WithMsgID
+// when several stores whose aggregate ids may collide publish into the same JetStream stream.
+//
+// Publish waits for the stream to acknowledge each message, so the relay only checkpoints
+// records that JetStream has stored.  The subject records are published to must be bound to a
+// stream.
+package natspublisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// DefaultSubject is the subject records are published to unless WithSubject is provided
+	DefaultSubject = "eventsource.records"
+
+	// HeaderOffset contains the offset of the record within the stream
+	HeaderOffset = "Eventsource-Offset"
+
+	// HeaderAggregateID contains the aggregate id of the record
+	HeaderAggregateID = "Eventsource-Aggregate-Id"
+
+	// HeaderVersion contains the version of the record
+	HeaderVersion = "Eventsource-Version"
+
+	// HeaderMetadata contains the json encoded metadata of the record; omitted when the record
+	// has no metadata
+	HeaderMetadata = "Eventsource-Metadata"
+)
+
+// Option provides functional configuration for a *Publisher
+type Option func(*Publisher)
+
+// WithSubject specifies a func that returns the subject for each record e.g. to route records
+// by aggregate type
+func WithSubject(fn func(record eventsource.StreamRecord) string) Option {
+	return func(p *Publisher) {
+		p.subject = fn
+	}
+}
+
+// WithMsgID specifies a func that returns the Nats-Msg-Id header of each record; defaults to
+// DefaultMsgID.  Ids must be unique across every publisher sharing a JetStream stream as
+// JetStream discards messages whose id was seen within its duplicate window
+func WithMsgID(fn func(record eventsource.StreamRecord) string) Option {
+	return func(p *Publisher) {
+		p.msgID = fn
+	}
+}
+
+// DefaultMsgID returns the id of the record as <aggregate id>/<version>.  Unlike the offset,
+// the id does not change if the record is relayed again from a different stream
+func DefaultMsgID(record eventsource.StreamRecord) string {
+	return record.AggregateID + "/" + strconv.Itoa(record.Version)
+}
+
+// Publisher implements outbox.Publisher using a NATS JetStream context
+type Publisher struct {
+	js      jetstream.JetStream
+	subject func(record eventsource.StreamRecord) string
+	msgID   func(record eventsource.StreamRecord) string
+}
+
+// New returns a Publisher that publishes records using js
+func New(js jetstream.JetStream, opts ...Option) *Publisher {
+	p := &Publisher{
+		js: js,
+		subject: func(eventsource.StreamRecord) string {
+			return DefaultSubject
+		},
+		msgID: DefaultMsgID,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Publish implements outbox.Publisher.  Publish returns once the stream has acknowledged the
+// message; an error is returned if the acknowledgement fails or does not arrive before ctx is
+// done or, when ctx has no deadline, the default timeout of js elapses.  A message discarded
+// as a duplicate was stored by an earlier attempt and is not an error
+func (p *Publisher) Publish(ctx context.Context, record eventsource.StreamRecord) error {
+	offset := strconv.FormatUint(record.Offset, 10)
+
+	msg := nats.NewMsg(p.subject(record))
+	msg.Data = record.Data
+	msg.Header.Set(HeaderOffset, offset)
+	msg.Header.Set(HeaderAggregateID, record.AggregateID)
+	msg.Header.Set(HeaderVersion, strconv.Itoa(record.Version))
+	if len(record.Metadata) > 0 {
+		metadata, err := json.Marshal(record.Metadata)
+		if err != nil {
+			return fmt.Errorf("unable to encode metadata of record at offset, %v: %v", record.Offset, err)
+		}
+		msg.Header.Set(HeaderMetadata, string(metadata))
+	}
+
+	if _, err := p.js.PublishMsg(ctx, msg, jetstream.WithMsgID(p.msgID(record))); err != nil {
+		return fmt.Errorf("unable to publish record at offset, %v: %v", record.Offset, err)
+	}
+
+	return nil
+}
+
+// Decode converts a message sent by Publisher back into a StreamRecord
+func Decode(msg *nats.Msg) (eventsource.StreamRecord, error) {
+	offset, err := strconv.ParseUint(msg.Header.Get(HeaderOffset), 10, 64)
+	if err != nil {
+		return eventsource.StreamRecord{}, fmt.Errorf("invalid %v header: %v", HeaderOffset, err)
+	}
+
+	version, err := strconv.Atoi(msg.Header.Get(HeaderVersion))
+	if err != nil {
+		return eventsource.StreamRecord{}, fmt.Errorf("invalid %v header: %v", HeaderVersion, err)
+	}
+
+	var metadata map[string]string
+	if v := msg.Header.Get(HeaderMetadata); v != "" {
+		if err := json.Unmarshal([]byte(v), &metadata); err != nil {
+			return eventsource.StreamRecord{}, fmt.Errorf("invalid %v header: %v", HeaderMetadata, err)
+		}
+	}
+
+	return eventsource.StreamRecord{
+		Record: eventsource.Record{
+			Version:  version,
+			Data:     msg.Data,
+			Metadata: metadata,
+		},
+		Offset:      offset,
+		AggregateID: msg.Header.Get(HeaderAggregateID),
+	}, nil
+}
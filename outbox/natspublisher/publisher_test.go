@@ -0,0 +1,197 @@
+package natspublisher_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/outbox"
+	"github.com/eventsource-ecosystem/eventsource/outbox/natspublisher"
+	"github.com/eventsource-ecosystem/eventsource/subscription"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// connect returns a JetStream context connected to an in-process server; see runServer
+func connect(t *testing.T) jetstream.JetStream {
+	conn, err := nats.Connect(runServer(t))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	t.Cleanup(conn.Close)
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	return js
+}
+
+// newStream creates a JetStream stream that stores the messages published to subject
+func newStream(t *testing.T, js jetstream.JetStream, subject string) jetstream.Stream {
+	stream, err := js.CreateStream(context.Background(), jetstream.StreamConfig{
+		Name:     "EVENTSOURCE",
+		Subjects: []string{subject},
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	return stream
+}
+
+func TestDecode(t *testing.T) {
+	msg := nats.NewMsg(natspublisher.DefaultSubject)
+	msg.Data = []byte("data")
+	msg.Header.Set(natspublisher.HeaderOffset, "12")
+	msg.Header.Set(natspublisher.HeaderAggregateID, "abc")
+	msg.Header.Set(natspublisher.HeaderVersion, "3")
+	msg.Header.Set(natspublisher.HeaderMetadata, `{"actor":"alice"}`)
+
+	record, err := natspublisher.Decode(msg)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := eventsource.StreamRecord{
+		Record:      eventsource.Record{Version: 3, Data: []byte("data"), Metadata: map[string]string{"actor": "alice"}},
+		Offset:      12,
+		AggregateID: "abc",
+	}
+	if got := record; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	msg.Header.Del(natspublisher.HeaderOffset)
+	if _, err := natspublisher.Decode(msg); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	js := connect(t)
+	stream := newStream(t, js, natspublisher.DefaultSubject)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// interleave the events of several aggregates within the stream
+	store := eventsource.NewMemoryStore()
+	aggregateIDs := []string{"a", "b", "c"}
+	const versions = 10
+	for version := 1; version <= versions; version++ {
+		for _, id := range aggregateIDs {
+			data := []byte(fmt.Sprintf("%v-%v", id, version))
+			if err := store.Save(ctx, id, eventsource.Record{Version: version, Data: data}); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+		}
+	}
+
+	relay := outbox.New("nats", store, natspublisher.New(js), subscription.WithBatchSize(4))
+
+	done := make(chan error, 1)
+	go func() { done <- relay.Run(ctx) }()
+
+	consumer, err := stream.OrderedConsumer(ctx, jetstream.OrderedConsumerConfig{})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	latest := map[string]int{}
+	for received := 0; received < versions*len(aggregateIDs); {
+		batch, err := consumer.Fetch(versions*len(aggregateIDs)-received, jetstream.FetchMaxWait(5*time.Second))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		count := 0
+		for msg := range batch.Messages() {
+			count++
+			record, err := natspublisher.Decode(&nats.Msg{Header: msg.Headers(), Data: msg.Data()})
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got, want := record.Version, latest[record.AggregateID]+1; got != want {
+				t.Fatalf("got version %v for aggregate %v; want %v", got, record.AggregateID, want)
+			}
+			if got, want := string(record.Data), fmt.Sprintf("%v-%v", record.AggregateID, record.Version); got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := msg.Headers().Get(nats.MsgIdHdr), fmt.Sprintf("%v/%v", record.AggregateID, record.Version); got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			latest[record.AggregateID] = record.Version
+		}
+		if err := batch.Error(); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if count == 0 {
+			t.Fatalf("timed out waiting for message %v", received+1)
+		}
+		received += count
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+}
+
+func TestPublisher_Duplicate(t *testing.T) {
+	ctx := context.Background()
+	js := connect(t)
+	stream := newStream(t, js, natspublisher.DefaultSubject)
+
+	publisher := natspublisher.New(js, natspublisher.WithMsgID(func(record eventsource.StreamRecord) string {
+		return "orders/" + natspublisher.DefaultMsgID(record)
+	}))
+	record := eventsource.StreamRecord{
+		Record:      eventsource.Record{Version: 2, Data: []byte("data")},
+		Offset:      7,
+		AggregateID: "abc",
+	}
+
+	// the record is relayed again e.g. after a restart before the checkpoint was saved
+	for i := 0; i < 2; i++ {
+		if err := publisher.Publish(ctx, record); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := info.State.Msgs, uint64(1); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	msg, err := stream.GetMsg(ctx, 1)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := msg.Header.Get(nats.MsgIdHdr), "orders/abc/2"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestPublisher_NoStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// no stream is bound to the subject so no acknowledgement is received
+	publisher := natspublisher.New(connect(t))
+	record := eventsource.StreamRecord{
+		Record:      eventsource.Record{Version: 1, Data: []byte("data")},
+		Offset:      1,
+		AggregateID: "abc",
+	}
+	if err := publisher.Publish(ctx, record); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
@@ -0,0 +1,33 @@
+package natspublisher_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// runServer starts an in-process NATS server with JetStream enabled on a random local port and
+// returns its url; the server is shut down when the test completes
+func runServer(t *testing.T) string {
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	s.Start()
+	t.Cleanup(s.Shutdown)
+
+	if !s.ReadyForConnections(10 * time.Second) {
+		t.Fatalf("timed out waiting for server")
+	}
+
+	return s.ClientURL()
+}
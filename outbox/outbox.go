@@ -0,0 +1,95 @@
+// Package outbox relays records from the event stream to a message broker.
+//
+// Observers registered with eventsource.WithObservers run in-process after the save and events
+// are lost if the process stops before the observer completes.  A Relay instead tails an
+// eventsource.StreamReader, so the records saved by the Store serve as the outbox, and saves a
+// checkpoint once each record has been published.  Records are published at least once and in
+// stream order, which preserves the order of events within each aggregate; consumers should
+// be prepared to discard duplicates using the offset or aggregate id and version.
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/subscription"
+)
+
+// Publisher sends records to a message broker
+type Publisher interface {
+	// Publish sends the record to the broker and returns once the broker has accepted it
+	Publish(ctx context.Context, record eventsource.StreamRecord) error
+}
+
+// PublisherFunc provides a func alternative for declaring a Publisher
+type PublisherFunc func(ctx context.Context, record eventsource.StreamRecord) error
+
+// Publish implements the Publisher interface
+func (fn PublisherFunc) Publish(ctx context.Context, record eventsource.StreamRecord) error {
+	return fn(ctx, record)
+}
+
+// Relay publishes the records read from a StreamReader
+type Relay struct {
+	sub *subscription.Subscription
+}
+
+// New returns a Relay that publishes the records from reader using publisher.  name
+// identifies the relay's checkpoint.  Use subscription.WithCheckpointStore to persist
+// progress across restarts
+func New(name string, reader eventsource.StreamReader, publisher Publisher, opts ...subscription.Option) *Relay {
+	handler := subscription.HandlerFunc(func(ctx context.Context, record eventsource.StreamRecord, _ eventsource.Event) error {
+		return publisher.Publish(ctx, record)
+	})
+
+	return &Relay{
+		sub: subscription.New(name, reader, handler, opts...),
+	}
+}
+
+// Offset returns the offset of the last record published
+func (r *Relay) Offset() uint64 {
+	return r.sub.Offset()
+}
+
+// Run publishes records until ctx is cancelled.  Records that fail to publish are retried,
+// with backoff, before any subsequent record is published
+func (r *Relay) Run(ctx context.Context) error {
+	return r.sub.Run(ctx)
+}
+
+// Poll publishes a single batch of records and returns the number published
+func (r *Relay) Poll(ctx context.Context) (int, error) {
+	return r.sub.Poll(ctx)
+}
+
+// MemoryPublisher provides an in-memory Publisher suitable for testing
+type MemoryPublisher struct {
+	mux     sync.Mutex
+	records []eventsource.StreamRecord
+}
+
+// NewMemoryPublisher returns an empty MemoryPublisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Publish implements Publisher
+func (m *MemoryPublisher) Publish(ctx context.Context, record eventsource.StreamRecord) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.records = append(m.records, record)
+	return nil
+}
+
+// Records returns the records published so far
+func (m *MemoryPublisher) Records() []eventsource.StreamRecord {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	records := make([]eventsource.StreamRecord, len(m.records))
+	copy(records, m.records)
+	return records
+}
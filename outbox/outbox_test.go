@@ -0,0 +1,90 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/outbox"
+	"github.com/eventsource-ecosystem/eventsource/subscription"
+)
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	store := eventsource.NewMemoryStore()
+	store.Save(ctx, "a", eventsource.Record{Version: 1}, eventsource.Record{Version: 2})
+	store.Save(ctx, "b", eventsource.Record{Version: 1})
+
+	checkpoints := subscription.NewMemoryCheckpointStore()
+	publisher := outbox.NewMemoryPublisher()
+	relay := outbox.New("outbox", store, publisher, subscription.WithCheckpointStore(checkpoints))
+
+	if _, err := relay.Poll(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(publisher.Records()), 3; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	offset, err := checkpoints.LoadCheckpoint(ctx, "outbox")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := offset, uint64(3); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestRelay_Retry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := eventsource.NewMemoryStore()
+	for version := 1; version <= 3; version++ {
+		store.Save(ctx, "a", eventsource.Record{Version: version})
+	}
+
+	var mux sync.Mutex
+	var failed bool
+	publisher := outbox.NewMemoryPublisher()
+	flaky := outbox.PublisherFunc(func(ctx context.Context, record eventsource.StreamRecord) error {
+		mux.Lock()
+		defer mux.Unlock()
+
+		if record.Offset == 2 && !failed {
+			failed = true
+			return errors.New("broker unavailable")
+		}
+		return publisher.Publish(ctx, record)
+	})
+
+	relay := outbox.New("outbox", store, flaky,
+		subscription.WithPollInterval(time.Millisecond),
+		subscription.WithBackoff(time.Millisecond, time.Millisecond),
+	)
+
+	done := make(chan error, 1)
+	go func() { done <- relay.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(publisher.Records()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for records")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// the failed record must be published before any record that follows it
+	for i, record := range publisher.Records() {
+		if got, want := record.Version, i+1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package projection
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// SwitchFunc is called by BlueGreen once the next projection has caught up with the stream
+// e.g. to repoint a view or alias from the old read model to the new one
+type SwitchFunc func(ctx context.Context, from, to *Projection) error
+
+// BlueGreen builds a new version of a projection alongside the version currently serving
+// reads.  The current version continues to run while the next version replays the stream;
+// once the next version has caught up, the SwitchFunc is invoked and the current version is
+// stopped
+type BlueGreen struct {
+	current    *Projection
+	next       *Projection
+	switchover SwitchFunc
+
+	mux    sync.Mutex
+	active *Projection
+}
+
+// NewBlueGreen returns a BlueGreen that replaces current with next.  switchover may be nil
+func NewBlueGreen(current, next *Projection, switchover SwitchFunc) *BlueGreen {
+	return &BlueGreen{
+		current:    current,
+		next:       next,
+		switchover: switchover,
+		active:     current,
+	}
+}
+
+// Active returns the projection currently serving reads
+func (b *BlueGreen) Active() *Projection {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.active
+}
+
+// Run runs the current projection while the next projection catches up, switches to the
+// next projection, and then runs it until ctx is cancelled.  If the next projection fails to
+// catch up, the current projection is stopped and the error is returned
+func (b *BlueGreen) Run(ctx context.Context) error {
+	currentCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- b.current.Run(currentCtx) }()
+
+	stop := func() error {
+		cancel()
+		return <-done
+	}
+
+	if err := b.next.CatchUp(ctx); err != nil {
+		stop()
+		if ctx.Err() != nil {
+			return nil
+		}
+		return xerrors.Errorf("unable to catch up projection, %v: %w", b.next.CheckpointName(), err)
+	}
+
+	if b.switchover != nil {
+		if err := b.switchover(ctx, b.current, b.next); err != nil {
+			stop()
+			return xerrors.Errorf("unable to switch from projection, %v, to %v: %w", b.current.CheckpointName(), b.next.CheckpointName(), err)
+		}
+	}
+
+	b.mux.Lock()
+	b.active = b.next
+	b.mux.Unlock()
+
+	if err := stop(); err != nil {
+		return err
+	}
+
+	return b.next.Run(ctx)
+}
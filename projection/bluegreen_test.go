@@ -0,0 +1,73 @@
+package projection_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/projection"
+	"github.com/eventsource-ecosystem/eventsource/subscription"
+)
+
+func TestBlueGreen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := newStore(t, 3)
+	checkpoints := subscription.NewMemoryCheckpointStore()
+	serializer := eventsource.NewJSONSerializer(OrderPlaced{})
+	opts := []projection.Option{
+		projection.WithCheckpointStore(checkpoints),
+		projection.WithSubscriptionOptions(subscription.WithPollInterval(time.Millisecond)),
+	}
+
+	blue := &totals{}
+	current := projection.New("totals", 1, store, serializer, opts...)
+	current.On(OrderPlaced{}, blue.add)
+	if err := current.CatchUp(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// the new version doubles the amount of each order
+	green := &totals{}
+	next := projection.New("totals", 2, store, serializer, opts...)
+	next.On(OrderPlaced{}, func(ctx context.Context, event eventsource.Event) error {
+		if err := green.add(ctx, event); err != nil {
+			return err
+		}
+		return green.add(ctx, event)
+	})
+
+	switched := make(chan struct{})
+	bg := projection.NewBlueGreen(current, next, func(ctx context.Context, from, to *projection.Projection) error {
+		if got, want := green.Total(), 12; got != want {
+			t.Errorf("got %v; want %v", got, want)
+		}
+		close(switched)
+		return nil
+	})
+	if got, want := bg.Active(), current; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- bg.Run(ctx) }()
+
+	select {
+	case <-switched:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for switch")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := bg.Active(), next; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := blue.Total(), 6; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+// Package projection builds read models from the global event stream.
+//
+// A Projection registers a handler per event type and consumes an eventsource.StreamReader
+// using a subscription.Subscription.  Records whose event type has no handler, or that the
+// serializer does not recognize, are skipped.
+//
+//	p := projection.New("order-totals", 1, store, serializer,
+//		projection.WithCheckpointStore(checkpoints),
+//		projection.WithReset(truncateTotals),
+//	)
+//	p.On(OrderPlaced{}, func(ctx context.Context, event eventsource.Event) error {
+//		placed := event.(*OrderPlaced)
+//		...
+//	})
+//	err := p.Run(ctx)
+//
+// The checkpoint is keyed by both the name and version of the projection so bumping the
+// version when the projection code changes causes the read model to be rebuilt from the
+// beginning of the stream.  See BlueGreen to build the new version alongside the old.
+package projection
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/subscription"
+	"golang.org/x/xerrors"
+)
+
+// HandlerFunc applies a single event to the read model
+type HandlerFunc func(ctx context.Context, event eventsource.Event) error
+
+// ResetFunc discards the read model for the specified version of the projection e.g. by
+// truncating its tables
+type ResetFunc func(ctx context.Context, version int) error
+
+// Option provides functional configuration for a *Projection
+type Option func(*Projection)
+
+// WithCheckpointStore specifies where offsets are saved; defaults to an in-memory store
+func WithCheckpointStore(checkpoints subscription.CheckpointStore) Option {
+	return func(p *Projection) {
+		p.checkpoints = checkpoints
+	}
+}
+
+// WithReset specifies the func used by Rebuild to discard the existing read model
+func WithReset(fn ResetFunc) Option {
+	return func(p *Projection) {
+		p.reset = fn
+	}
+}
+
+// WithSubscriptionOptions configures the underlying subscription e.g. batch size and poll
+// interval
+func WithSubscriptionOptions(opts ...subscription.Option) Option {
+	return func(p *Projection) {
+		p.opts = append(p.opts, opts...)
+	}
+}
+
+// Projection applies events from the global stream to a read model
+type Projection struct {
+	name        string
+	version     int
+	reader      eventsource.StreamReader
+	serializer  eventsource.Serializer
+	checkpoints subscription.CheckpointStore
+	reset       ResetFunc
+	opts        []subscription.Option
+
+	mux      sync.Mutex
+	handlers map[string]HandlerFunc
+	sub      *subscription.Subscription
+}
+
+// New returns a Projection that reads records from reader and decodes them with serializer.
+// version identifies the revision of the projection code and should be incremented whenever
+// a change requires the read model to be rebuilt
+func New(name string, version int, reader eventsource.StreamReader, serializer eventsource.Serializer, opts ...Option) *Projection {
+	p := &Projection{
+		name:        name,
+		version:     version,
+		reader:      reader,
+		serializer:  serializer,
+		checkpoints: subscription.NewMemoryCheckpointStore(),
+		handlers:    map[string]HandlerFunc{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.sub = p.newSubscription()
+
+	return p
+}
+
+func (p *Projection) newSubscription() *subscription.Subscription {
+	opts := append([]subscription.Option{subscription.WithCheckpointStore(p.checkpoints)}, p.opts...)
+	return subscription.New(p.CheckpointName(), p.reader, p, opts...)
+}
+
+// Name returns the name of the projection
+func (p *Projection) Name() string {
+	return p.name
+}
+
+// Version returns the version of the projection
+func (p *Projection) Version() int {
+	return p.version
+}
+
+// CheckpointName returns the name under which the projection's checkpoint is saved
+func (p *Projection) CheckpointName() string {
+	return fmt.Sprintf("%v/v%v", p.name, p.version)
+}
+
+// On registers fn as the handler for events of the same type as prototype; registering a
+// second handler for the same type replaces the first
+func (p *Projection) On(prototype eventsource.Event, fn HandlerFunc) {
+	eventType, _ := eventsource.EventType(prototype)
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.handlers[eventType] = fn
+}
+
+// Handle implements subscription.Handler
+func (p *Projection) Handle(ctx context.Context, record eventsource.StreamRecord, _ eventsource.Event) error {
+	events, err := eventsource.UnmarshalEvents(p.serializer, record.Record)
+	if err != nil {
+		if eventsource.IsUnboundEventTypeError(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, event := range events {
+		eventType, _ := eventsource.EventType(event)
+
+		p.mux.Lock()
+		fn, ok := p.handlers[eventType]
+		p.mux.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		if err := fn(ctx, event); err != nil {
+			return xerrors.Errorf("projection, %v, unable to handle event, %v: %w", p.CheckpointName(), eventType, err)
+		}
+	}
+
+	return nil
+}
+
+// currentSubscription returns the subscription in use
+func (p *Projection) currentSubscription() *subscription.Subscription {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return p.sub
+}
+
+// Subscription returns the subscription used to consume the stream e.g. to inspect or replay
+// dead letters when the projection was configured with subscription.WithDeadLetters
+func (p *Projection) Subscription() *subscription.Subscription {
+	return p.currentSubscription()
+}
+
+// Offset returns the offset of the last record applied to the read model
+func (p *Projection) Offset() uint64 {
+	return p.currentSubscription().Offset()
+}
+
+// Run applies events to the read model until ctx is cancelled
+func (p *Projection) Run(ctx context.Context) error {
+	return p.currentSubscription().Run(ctx)
+}
+
+// CatchUp applies events until the end of the stream has been reached
+func (p *Projection) CatchUp(ctx context.Context) error {
+	sub := p.currentSubscription()
+	for {
+		n, err := sub.Poll(ctx)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+// Rebuild discards the read model, using the ResetFunc if one was provided, and replays the
+// stream from the beginning.  Rebuild must not be called while Run is active
+func (p *Projection) Rebuild(ctx context.Context) error {
+	if p.reset != nil {
+		if err := p.reset(ctx, p.version); err != nil {
+			return xerrors.Errorf("unable to reset projection, %v: %w", p.CheckpointName(), err)
+		}
+	}
+
+	if err := p.checkpoints.SaveCheckpoint(ctx, p.CheckpointName(), 0); err != nil {
+		return xerrors.Errorf("unable to reset checkpoint for projection, %v: %w", p.CheckpointName(), err)
+	}
+
+	p.mux.Lock()
+	p.sub = p.newSubscription()
+	p.mux.Unlock()
+
+	return p.CatchUp(ctx)
+}
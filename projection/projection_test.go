@@ -0,0 +1,173 @@
+package projection_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/projection"
+	"github.com/eventsource-ecosystem/eventsource/subscription"
+)
+
+type OrderPlaced struct {
+	eventsource.Model
+	Amount int
+}
+
+type OrderShipped struct {
+	eventsource.Model
+}
+
+type OrderAudited struct {
+	eventsource.Model
+}
+
+// newStore returns a store containing n orders, each placed and shipped, along with an
+// audit event the projection's serializer does not know about
+func newStore(t *testing.T, n int) *eventsource.MemoryStore {
+	ctx := context.Background()
+	serializer := eventsource.NewJSONSerializer(OrderPlaced{}, OrderShipped{}, OrderAudited{})
+	store := eventsource.NewMemoryStore()
+	for i := 1; i <= n; i++ {
+		id := string(rune('a' + i - 1))
+		history, err := serializer.MarshalAll(
+			OrderPlaced{Model: eventsource.Model{ID: id, Version: 1}, Amount: i},
+			OrderShipped{Model: eventsource.Model{ID: id, Version: 2}},
+			OrderAudited{Model: eventsource.Model{ID: id, Version: 3}},
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if err := store.Save(ctx, id, history...); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+	return store
+}
+
+// totals provides a trivial read model
+type totals struct {
+	mux   sync.Mutex
+	total int
+}
+
+func (t *totals) Total() int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return t.total
+}
+
+func (t *totals) add(ctx context.Context, event eventsource.Event) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.total += event.(*OrderPlaced).Amount
+	return nil
+}
+
+func (t *totals) reset(ctx context.Context, version int) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.total = 0
+	return nil
+}
+
+func TestProjection(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, 3)
+	model := &totals{}
+
+	p := projection.New("totals", 1, store, eventsource.NewJSONSerializer(OrderPlaced{}, OrderShipped{}))
+	p.On(OrderPlaced{}, model.add)
+
+	if err := p.CatchUp(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := model.Total(), 6; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := p.Offset(), uint64(9); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestProjection_Rebuild(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, 3)
+	model := &totals{}
+
+	p := projection.New("totals", 1, store, eventsource.NewJSONSerializer(OrderPlaced{}),
+		projection.WithReset(model.reset),
+	)
+	p.On(OrderPlaced{}, model.add)
+
+	for i := 0; i < 2; i++ {
+		if err := p.Rebuild(ctx); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := model.Total(), 6; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
+
+func TestProjection_VersionedCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, 2)
+	checkpoints := subscription.NewMemoryCheckpointStore()
+	serializer := eventsource.NewJSONSerializer(OrderPlaced{})
+
+	v1 := projection.New("totals", 1, store, serializer, projection.WithCheckpointStore(checkpoints))
+	if err := v1.CatchUp(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	model := &totals{}
+	v2 := projection.New("totals", 2, store, serializer, projection.WithCheckpointStore(checkpoints))
+	v2.On(OrderPlaced{}, model.add)
+	if err := v2.CatchUp(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// v2 should not share the checkpoint written by v1
+	if got, want := model.Total(), 3; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestProjection_DeadLetters(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, 2)
+	if err := store.Save(ctx, "z", eventsource.Record{Version: 1, Data: []byte("malformed")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	model := &totals{}
+
+	p := projection.New("totals", 1, store, eventsource.NewJSONSerializer(OrderPlaced{}),
+		projection.WithSubscriptionOptions(
+			subscription.WithDeadLetters(subscription.NewMemoryDeadLetterStore(), 1),
+		),
+	)
+	p.On(OrderPlaced{}, model.add)
+
+	if err := p.CatchUp(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := model.Total(), 3; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	parked, err := p.Subscription().DeadLetters(ctx)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(parked), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := parked[0].Record.AggregateID, "z"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
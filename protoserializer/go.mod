@@ -0,0 +1,12 @@
+module github.com/eventsource-ecosystem/eventsource/protoserializer
+
+go 1.23
+
+require github.com/eventsource-ecosystem/eventsource v0.0.0-00010101000000-000000000000
+
+require (
+	golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7 // indirect
+	google.golang.org/protobuf v1.36.11
+)
+
+replace github.com/eventsource-ecosystem/eventsource => ..
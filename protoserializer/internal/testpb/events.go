@@ -0,0 +1,27 @@
+// Package testpb contains the protobuf events used by the protoserializer tests.  events.pb.go
+// is generated from events.proto with protoc-gen-go
+package testpb
+
+import "time"
+
+// AggregateID implements eventsource.Event
+func (x *OrderPlaced) AggregateID() string { return x.GetId() }
+
+// EventVersion implements eventsource.Event
+func (x *OrderPlaced) EventVersion() int { return int(x.GetVersion()) }
+
+// EventAt implements eventsource.Event
+func (x *OrderPlaced) EventAt() time.Time { return x.GetAt().AsTime() }
+
+// AggregateID implements eventsource.Event
+func (x *OrderShipped) AggregateID() string { return x.GetId() }
+
+// EventVersion implements eventsource.Event
+func (x *OrderShipped) EventVersion() int { return int(x.GetVersion()) }
+
+// EventAt implements eventsource.Event
+func (x *OrderShipped) EventAt() time.Time { return x.GetAt().AsTime() }
+
+// EventType implements eventsource.EventTyper, keeping the name used before the event was
+// converted to protobuf
+func (x *OrderShipped) EventType() string { return "OrderShipped" }
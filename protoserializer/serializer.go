@@ -0,0 +1,203 @@
+// Package protoserializer provides an eventsource.Serializer for events that are protobuf
+// messages.
+//
+// Each record contains a compact envelope, itself a protobuf message, holding the event type
+// and the marshaled event:
+//
+//	message Envelope {
+//	  string type = 1;
+//	  bytes  data = 2;
+//	}
+//
+// The event is marshaled directly into the envelope so it is only encoded once.  Event types
+// default to the full name of the message e.g. orders.v1.OrderPlaced.  Events that implement
+// eventsource.EventTyper are stored under the name they return, and WithGoTypeNames stores every
+// event under the name assigned by eventsource.EventType, so existing type names can be kept
+// when events are converted to protobuf.
+package protoserializer
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// typeField contains the event type within the envelope
+	typeField protowire.Number = 1
+
+	// dataField contains the marshaled event within the envelope
+	dataField protowire.Number = 2
+)
+
+// Option provides functional configuration for a *Serializer
+type Option func(*Serializer)
+
+// WithGoTypeNames stores events under the name returned by eventsource.EventType, the name of
+// the Go type unless the event implements eventsource.EventTyper, rather than the full name of
+// the message
+func WithGoTypeNames() Option {
+	return func(s *Serializer) {
+		s.goTypeNames = true
+	}
+}
+
+// Serializer converts between protobuf events and Records
+type Serializer struct {
+	goTypeNames bool
+	eventTypes  map[string]reflect.Type
+}
+
+// New returns a Serializer with no events bound; see Bind
+func New(opts ...Option) *Serializer {
+	s := &Serializer{
+		eventTypes: map[string]reflect.Type{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Bind registers the specified events with the serializer; may be called more than once.  Each
+// event must be a pointer to a generated protobuf message
+func (s *Serializer) Bind(events ...eventsource.Event) error {
+	for _, event := range events {
+		msg, ok := event.(proto.Message)
+		if !ok {
+			return fmt.Errorf("unable to bind %T: does not implement proto.Message", event)
+		}
+
+		t := reflect.TypeOf(event)
+		if t.Kind() != reflect.Ptr {
+			return fmt.Errorf("unable to bind %T: expected pointer to message", event)
+		}
+
+		s.eventTypes[s.eventType(event, msg)] = t.Elem()
+	}
+	return nil
+}
+
+// eventType returns the name the event is stored under
+func (s *Serializer) eventType(event eventsource.Event, msg proto.Message) string {
+	if _, ok := event.(eventsource.EventTyper); ok || s.goTypeNames {
+		eventType, _ := eventsource.EventType(event)
+		return eventType
+	}
+	return string(msg.ProtoReflect().Descriptor().FullName())
+}
+
+// MarshalEvent implements eventsource.Serializer
+func (s *Serializer) MarshalEvent(event eventsource.Event) (eventsource.Record, error) {
+	msg, ok := event.(proto.Message)
+	if !ok {
+		return eventsource.Record{}, fmt.Errorf("unable to marshal %T: does not implement proto.Message", event)
+	}
+
+	eventType := s.eventType(event, msg)
+	size := proto.Size(msg)
+
+	capacity := protowire.SizeTag(typeField) + protowire.SizeBytes(len(eventType)) +
+		protowire.SizeTag(dataField) + protowire.SizeBytes(size)
+
+	data := make([]byte, 0, capacity)
+	data = protowire.AppendTag(data, typeField, protowire.BytesType)
+	data = protowire.AppendString(data, eventType)
+	data = protowire.AppendTag(data, dataField, protowire.BytesType)
+	data = protowire.AppendVarint(data, uint64(size))
+
+	// proto.Size has already computed the size of msg
+	data, err := proto.MarshalOptions{UseCachedSize: true}.MarshalAppend(data, msg)
+	if err != nil {
+		return eventsource.Record{}, fmt.Errorf("unable to marshal event, %v: %v", eventType, err)
+	}
+
+	return eventsource.Record{
+		Version: event.EventVersion(),
+		Data:    data,
+	}, nil
+}
+
+// UnmarshalEvent implements eventsource.Serializer.  Returns an error detectable with
+// eventsource.IsUnboundEventTypeError if the event type has not been bound
+func (s *Serializer) UnmarshalEvent(record eventsource.Record) (eventsource.Event, error) {
+	eventType, data, err := decodeEnvelope(record.Data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal envelope of record at version, %v: %v", record.Version, err)
+	}
+
+	t, ok := s.eventTypes[eventType]
+	if !ok {
+		return nil, eventsource.NewUnboundEventTypeError(eventType)
+	}
+
+	v := reflect.New(t).Interface()
+	if err := proto.Unmarshal(data, v.(proto.Message)); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal event, %v: %v", eventType, err)
+	}
+
+	return v.(eventsource.Event), nil
+}
+
+// MarshalAll is a utility that marshals all the events provided into a History object
+func (s *Serializer) MarshalAll(events ...eventsource.Event) (eventsource.History, error) {
+	history := make(eventsource.History, 0, len(events))
+	for _, event := range events {
+		record, err := s.MarshalEvent(event)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, record)
+	}
+	return history, nil
+}
+
+// decodeEnvelope returns the event type and marshaled event contained in the envelope.  Unknown
+// fields are ignored
+func decodeEnvelope(b []byte) (string, []byte, error) {
+	var eventType string
+	var data []byte
+	var found bool
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return "", nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch {
+		case num == typeField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return "", nil, protowire.ParseError(n)
+			}
+			eventType, found = v, true
+			b = b[n:]
+
+		case num == dataField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return "", nil, protowire.ParseError(n)
+			}
+			data = v
+			b = b[n:]
+
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return "", nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+
+	if !found {
+		return "", nil, fmt.Errorf("envelope does not contain an event type")
+	}
+
+	return eventType, data, nil
+}
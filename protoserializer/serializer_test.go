@@ -0,0 +1,102 @@
+package protoserializer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/protoserializer"
+	"github.com/eventsource-ecosystem/eventsource/protoserializer/internal/testpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSerializer(t *testing.T) {
+	serializer := protoserializer.New()
+	if err := serializer.Bind(&testpb.OrderPlaced{}, &testpb.OrderShipped{}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []eventsource.Event{
+		&testpb.OrderPlaced{Id: "abc", Version: 1, At: timestamppb.New(at), Amount: 100},
+		&testpb.OrderShipped{Id: "abc", Version: 2, At: timestamppb.New(at)},
+	}
+
+	history, err := serializer.MarshalAll(events...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	for i, record := range history {
+		if got, want := record.Version, i+1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		event, err := serializer.UnmarshalEvent(record)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := event.(proto.Message), events[i].(proto.Message); !proto.Equal(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := event.EventAt(), at; !got.Equal(want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+
+	// messages are stored under their full name unless they implement EventTyper
+	if got, want := string(history[0].Data), "eventsource.test.OrderPlaced"; !strings.Contains(got, want) {
+		t.Fatalf("got %q; want to contain %v", got, want)
+	}
+	if got, want := string(history[1].Data), "OrderShipped"; !strings.Contains(got, want) || strings.Contains(got, "eventsource.test") {
+		t.Fatalf("got %q; want to contain %v only", got, want)
+	}
+}
+
+func TestSerializer_GoTypeNames(t *testing.T) {
+	serializer := protoserializer.New(protoserializer.WithGoTypeNames())
+	if err := serializer.Bind(&testpb.OrderPlaced{}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	record, err := serializer.MarshalEvent(&testpb.OrderPlaced{Id: "abc", Version: 1})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := string(record.Data), "OrderPlaced"; !strings.Contains(got, want) || strings.Contains(got, "eventsource.test") {
+		t.Fatalf("got %q; want to contain %v only", got, want)
+	}
+
+	event, err := serializer.UnmarshalEvent(record)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := event.AggregateID(), "abc"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSerializer_Errors(t *testing.T) {
+	serializer := protoserializer.New()
+
+	record, err := serializer.MarshalEvent(&testpb.OrderPlaced{Id: "abc", Version: 1})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if _, err := serializer.UnmarshalEvent(record); !eventsource.IsUnboundEventTypeError(err) {
+		t.Fatalf("got %v; want error detectable with IsUnboundEventTypeError", err)
+	}
+
+	if _, err := serializer.UnmarshalEvent(eventsource.Record{Data: []byte{0xff}}); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+
+	if err := serializer.Bind(eventsource.Model{}); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	if _, err := serializer.MarshalEvent(eventsource.Model{}); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
@@ -26,9 +26,17 @@ type Repository struct {
 	prototype  reflect.Type
 	store      Store
 	serializer Serializer
-	observers  []func(Event)
+	observers  []Observer
+	retry      *RetryPolicy
+	snapshots  SnapshotStore
+	policy     SnapshotPolicy
+	migrator   SnapshotMigrator
 	writer     io.Writer
 	debug      bool
+
+	hooks          []Hooks
+	observerErrors ObserverErrorHandler
+	queue          *observerQueue
 }
 
 // Option provides functional configuration for a *Repository
@@ -58,10 +66,17 @@ func WithSerializer(serializer Serializer) Option {
 }
 
 // WithObservers allows observers to watch the saved events; Observers should invoke very short lived operations as
-// calls will block until the observer is finished
+// calls will block until the observer is finished.  See WithObserver for observers that receive the context and
+// may return errors
 func WithObservers(observers ...func(event Event)) Option {
 	return func(r *Repository) {
-		r.observers = append(r.observers, observers...)
+		for _, observer := range observers {
+			fn := observer
+			r.observers = append(r.observers, ObserverFunc(func(_ context.Context, notification Notification) error {
+				fn(notification.Event)
+				return nil
+			}))
+		}
 	}
 }
 
@@ -74,7 +89,7 @@ func New(prototype Aggregate, opts ...Option) *Repository {
 
 	r := &Repository{
 		prototype:  t,
-		store:      newMemoryStore(),
+		store:      NewMemoryStore(),
 		serializer: NewJSONSerializer(),
 	}
 
@@ -105,57 +120,167 @@ func (r *Repository) newAggregate() Aggregate {
 	return reflect.New(r.prototype).Interface().(Aggregate)
 }
 
-// Save persists the events into the underlying Store
+// Save persists the events into the underlying Store.  Hooks and observers are notified in
+// the same manner as Apply
 func (r *Repository) Save(ctx context.Context, events ...Event) error {
 	if len(events) == 0 {
 		return nil
 	}
 
 	aggregateID := events[0].AggregateID()
-	records, err := r.makeRecords(events)
+	if err := r.beforeSave(ctx, aggregateID, events); err != nil {
+		return err
+	}
+
+	records, err := r.makeRecords(ctx, events)
 	if err != nil {
 		return err
 	}
 
-	return r.store.Save(ctx, aggregateID, records...)
+	if err := r.store.Save(ctx, aggregateID, records...); err != nil {
+		return err
+	}
+
+	r.afterSave(ctx, aggregateID, events[len(events)-1].EventVersion(), events, records)
+	return nil
 }
 
 // Load retrieves the specified aggregate from the underlying store.  Returns the aggregate
 // along with the last event version
 func (r *Repository) Load(ctx context.Context, aggregateID string) (Aggregate, int, error) {
-	history, err := r.store.Load(ctx, aggregateID, 0, 0)
+	aggregate, version, _, err := r.load(ctx, aggregateID)
 	if err != nil {
 		return nil, 0, err
 	}
 
+	if err := r.afterLoad(ctx, aggregateID, aggregate, version); err != nil {
+		return nil, 0, err
+	}
+
+	return aggregate, version, nil
+}
+
+// load retrieves the specified aggregate, starting from the latest snapshot when snapshots are
+// enabled.  Returns the aggregate, the last event version and the version of the snapshot used
+func (r *Repository) load(ctx context.Context, aggregateID string) (Aggregate, int, int, error) {
+	aggregate, snapshotVersion, restored, stale := r.restoreSnapshot(ctx, aggregateID)
+	if !restored {
+		aggregate = r.newAggregate()
+	}
+
+	fromVersion := 0
+	if restored {
+		fromVersion = snapshotVersion + 1
+	}
+
+	history, err := r.store.Load(ctx, aggregateID, fromVersion, 0)
+	if err != nil && !(restored && IsNotFoundError(err)) {
+		return nil, 0, 0, err
+	}
+
 	entryCount := len(history)
-	if entryCount == 0 {
-		return nil, 0, xerrors.Errorf("unable to load %T, %v: %w", r.newAggregate(), aggregateID, errAggregateNotFound)
+	if entryCount == 0 && !restored {
+		return nil, 0, 0, xerrors.Errorf("unable to load %T, %v: %w", r.newAggregate(), aggregateID, errAggregateNotFound)
 	}
 
 	r.logf("Loaded %v event(s) for aggregate id, %v", entryCount, aggregateID)
+
+	version, _, err := r.fold(aggregate, snapshotVersion, history, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	if stale {
+		r.saveSnapshot(ctx, aggregateID, aggregate, version)
+		snapshotVersion = version
+	}
+
+	return aggregate, version, snapshotVersion, nil
+}
+
+// LoadVersion retrieves the specified aggregate as it was at the specified version.  Returns the
+// aggregate along with the last event version, which will be less than version if the aggregate
+// has not yet reached it.  Point in time loads always replay history from the first event
+func (r *Repository) LoadVersion(ctx context.Context, aggregateID string, version int) (Aggregate, int, error) {
+	if version <= 0 {
+		return nil, 0, fmt.Errorf("version provided to Repository.LoadVersion must be greater than 0; got %v", version)
+	}
+
+	history, err := r.store.Load(ctx, aggregateID, 0, version)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return r.loadHistory(ctx, aggregateID, history, nil)
+}
+
+// LoadAt retrieves the specified aggregate as it was at the specified time; only events whose
+// EventAt is before at are applied.  Returns the aggregate along with the last event version
+// applied.  Point in time loads always replay history from the first event
+func (r *Repository) LoadAt(ctx context.Context, aggregateID string, at time.Time) (Aggregate, int, error) {
+	history, err := r.store.Load(ctx, aggregateID, 0, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return r.loadHistory(ctx, aggregateID, history, func(event Event) bool {
+		return event.EventAt().Before(at)
+	})
+}
+
+// loadHistory folds the history provided into a new aggregate; returns an error detectable with
+// IsNotFoundError if no events were applied
+func (r *Repository) loadHistory(ctx context.Context, aggregateID string, history History, accept func(Event) bool) (Aggregate, int, error) {
 	aggregate := r.newAggregate()
+	version, applied, err := r.fold(aggregate, 0, history, accept)
+	if err != nil {
+		return nil, 0, err
+	}
+	if applied == 0 {
+		return nil, 0, xerrors.Errorf("unable to load %T, %v: %w", aggregate, aggregateID, errAggregateNotFound)
+	}
+
+	r.logf("Loaded %v of %v event(s) for aggregate id, %v", applied, len(history), aggregateID)
+
+	if err := r.afterLoad(ctx, aggregateID, aggregate, version); err != nil {
+		return nil, 0, err
+	}
+
+	return aggregate, version, nil
+}
 
-	version := 0
+// fold applies each record in the history to the aggregate, stopping at the first event not
+// accepted when accept is non-nil.  Returns the version of the last event applied, or version
+// if none were, along with the number of events applied
+func (r *Repository) fold(aggregate Aggregate, version int, history History, accept func(Event) bool) (int, int, error) {
+	applied := 0
 	for _, record := range history {
-		event, err := r.serializer.UnmarshalEvent(record)
+		events, err := UnmarshalEvents(r.serializer, record)
 		if err != nil {
-			return nil, 0, err
+			return 0, 0, err
 		}
 
-		err = aggregate.On(event)
-		if err != nil {
-			eventType, _ := EventType(event)
-			return nil, 0, xerrors.Errorf("aggregate was unable to handle event, %v: %w", eventType, err)
-		}
+		for _, event := range events {
+			if accept != nil && !accept(event) {
+				return version, applied, nil
+			}
 
-		version = event.EventVersion()
+			err = aggregate.On(event)
+			if err != nil {
+				eventType, _ := EventType(event)
+				return 0, 0, xerrors.Errorf("aggregate was unable to handle event, %v: %w", eventType, err)
+			}
+
+			version = event.EventVersion()
+			applied++
+		}
 	}
 
-	return aggregate, version, nil
+	return version, applied, nil
 }
 
-func (r *Repository) makeRecords(events []Event) ([]Record, error) {
+// makeRecords serializes the events and attaches the metadata carried by ctx
+func (r *Repository) makeRecords(ctx context.Context, events []Event) ([]Record, error) {
 	records := make([]Record, 0, len(events))
 	for _, event := range events {
 		record, err := r.serializer.MarshalEvent(event)
@@ -164,10 +289,12 @@ func (r *Repository) makeRecords(events []Event) ([]Record, error) {
 		}
 		records = append(records, record)
 	}
+	withMetadata(ctx, records)
 	return records, nil
 }
 
-// Apply executes the command specified and returns the current version of the aggregate
+// Apply executes the command specified and returns the current version of the aggregate.  When
+// the repository was configured WithRetry, conflicting saves will cause the command to be reapplied
 func (r *Repository) Apply(ctx context.Context, command Command) (int, error) {
 	if command == nil {
 		return 0, errors.New("command provided to Repository.Apply may not be nil")
@@ -177,9 +304,24 @@ func (r *Repository) Apply(ctx context.Context, command Command) (int, error) {
 		return 0, errors.New("command provided to Repository.Apply may not contain a blank AggregateID")
 	}
 
-	aggregate, version, err := r.Load(ctx, aggregateID)
-	if err != nil {
+	if r.retry != nil {
+		return r.applyWithRetry(ctx, command)
+	}
+
+	return r.apply(ctx, command)
+}
+
+// apply makes a single attempt to execute the command against the current version of the aggregate
+func (r *Repository) apply(ctx context.Context, command Command) (int, error) {
+	aggregateID := command.AggregateID()
+	aggregate, version, snapshotVersion, err := r.load(ctx, aggregateID)
+	if IsNotFoundError(err) {
 		aggregate = r.newAggregate()
+		version, snapshotVersion = 0, 0
+	} else if err != nil {
+		return 0, err
+	} else if err := r.afterLoad(ctx, aggregateID, aggregate, version); err != nil {
+		return 0, err
 	}
 
 	h, ok := aggregate.(CommandHandler)
@@ -191,36 +333,46 @@ func (r *Repository) Apply(ctx context.Context, command Command) (int, error) {
 		return 0, err
 	}
 
-	records, err := r.makeRecords(events)
+	if len(events) > 0 {
+		if err := r.beforeSave(ctx, aggregateID, events); err != nil {
+			return 0, err
+		}
+	}
+
+	records, err := r.makeRecords(ctx, events)
 	if err != nil {
 		return 0, err
 	}
 
-	if saver, ok := r.store.(AggregateSaver); ok {
+	_, isSaver := r.store.(AggregateSaver)
+	_, isStoreAggregate := r.store.(StoreAggregate)
+	if isSaver || isStoreAggregate || r.snapshots != nil {
 		for _, event := range events {
 			if err := aggregate.On(event); err != nil {
 				return 0, fmt.Errorf("unable to apply generated events to aggregate, %v: %v", aggregateID, err)
 			}
 		}
+	}
 
+	if saver, ok := r.store.(AggregateSaver); ok {
 		input := SaveAggregateInput{
-			AggregateID: aggregateID,
-			Aggregate:   aggregate,
-			Events:      events,
-			Records:     records,
+			AggregateID:     aggregateID,
+			Aggregate:       aggregate,
+			Events:          events,
+			Records:         records,
+			ExpectedVersion: version,
 		}
 		if err := saver.SaveAggregate(ctx, input); err != nil {
 			return 0, err
 		}
 
 	} else if store, ok := r.store.(StoreAggregate); ok {
-		for _, event := range events {
-			if err := aggregate.On(event); err != nil {
-				return 0, fmt.Errorf("unable to apply generated events to aggregate, %v: %v", aggregateID, err)
-			}
+		if err := store.SaveAggregate(ctx, aggregateID, aggregate, records...); err != nil {
+			return 0, err
 		}
 
-		if err := store.SaveAggregate(ctx, aggregateID, aggregate, records...); err != nil {
+	} else if store, ok := r.store.(VersionedStore); ok {
+		if err := store.SaveVersion(ctx, aggregateID, version, records...); err != nil {
 			return 0, err
 		}
 
@@ -230,15 +382,10 @@ func (r *Repository) Apply(ctx context.Context, command Command) (int, error) {
 
 	if v := len(events); v > 0 {
 		version = events[v-1].EventVersion()
-	}
+		r.takeSnapshot(ctx, aggregateID, aggregate, snapshotVersion, version)
 
-	// publish events to observers
-	if r.observers != nil {
-		for _, event := range events {
-			for _, observer := range r.observers {
-				observer(event)
-			}
-		}
+		// publish events to hooks and observers
+		r.afterSave(ctx, aggregateID, version, events, records)
 	}
 
 	return version, nil
@@ -2,6 +2,7 @@ package eventsource_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"testing"
@@ -347,3 +348,153 @@ func TestApplyNopCommand(t *testing.T) {
 		}
 	})
 }
+
+type versionedStore interface {
+	eventsource.Store
+	eventsource.VersionedStore
+}
+
+// staleStore never returns any history, simulating a writer that loaded the aggregate before
+// a concurrent writer saved it
+type staleStore struct {
+	versionedStore
+}
+
+func (s staleStore) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	return nil, nil
+}
+
+func TestApplyConflict(t *testing.T) {
+	store := staleStore{
+		versionedStore: eventsource.New(&Entity{}).Store().(versionedStore),
+	}
+	repo := eventsource.New(&Entity{},
+		eventsource.WithStore(store),
+		eventsource.WithSerializer(
+			eventsource.NewJSONSerializer(
+				EntityCreated{},
+			),
+		),
+	)
+
+	ctx := context.Background()
+	cmd := &CreateEntity{CommandModel: eventsource.CommandModel{ID: "123"}}
+
+	_, err := repo.Apply(ctx, cmd)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	_, err = repo.Apply(ctx, cmd)
+	if err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	if !eventsource.IsConflictError(err) {
+		t.Fatalf("got false; want true")
+	}
+
+	history, err := store.versionedStore.Load(ctx, "123", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(history), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+// unavailableStore fails every Load, simulating a transient store outage
+type unavailableStore struct {
+	versionedStore
+}
+
+func (s unavailableStore) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func TestApplyLoadError(t *testing.T) {
+	store := unavailableStore{
+		versionedStore: eventsource.New(&Entity{}).Store().(versionedStore),
+	}
+	repo := eventsource.New(&Entity{},
+		eventsource.WithStore(store),
+		eventsource.WithSerializer(
+			eventsource.NewJSONSerializer(
+				EntityCreated{},
+			),
+		),
+		eventsource.WithRetry(eventsource.RetryPolicy{MaxAttempts: 3}),
+	)
+
+	ctx := context.Background()
+	_, err := repo.Apply(ctx, &CreateEntity{CommandModel: eventsource.CommandModel{ID: "123"}})
+	if err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	if eventsource.IsConflictError(err) {
+		t.Fatalf("got true; want false")
+	}
+
+	if _, err := store.versionedStore.Load(ctx, "123", 0, 0); !eventsource.IsNotFoundError(err) {
+		t.Fatalf("got %v; want not found", err)
+	}
+}
+
+func TestPointInTime(t *testing.T) {
+	ctx := context.Background()
+	id := "123"
+	repo := eventsource.New(&Entity{},
+		eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{}, EntityNameSet{})),
+	)
+
+	err := repo.Save(ctx,
+		&EntityCreated{Model: eventsource.Model{ID: id, Version: 1, At: time.Unix(10, 0)}},
+		&EntityNameSet{Model: eventsource.Model{ID: id, Version: 2, At: time.Unix(20, 0)}, Name: "a"},
+		&EntityNameSet{Model: eventsource.Model{ID: id, Version: 3, At: time.Unix(30, 0)}, Name: "b"},
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	t.Run("LoadVersion", func(t *testing.T) {
+		v, version, err := repo.LoadVersion(ctx, id, 2)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := version, 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := v.(*Entity).Name, "a"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("LoadVersion past latest", func(t *testing.T) {
+		_, version, err := repo.LoadVersion(ctx, id, 10)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := version, 3; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("LoadAt", func(t *testing.T) {
+		v, version, err := repo.LoadAt(ctx, id, time.Unix(25, 0))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := version, 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := v.(*Entity).Name, "a"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("LoadAt before first event", func(t *testing.T) {
+		_, _, err := repo.LoadAt(ctx, id, time.Unix(5, 0))
+		if !eventsource.IsNotFoundError(err) {
+			t.Fatalf("got %v; want not found", err)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package eventsource
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultMaxAttempts = 3
+
+	// defaultMaxBackoff caps the delay between attempts when RetryPolicy.MaxBackoff is not set
+	defaultMaxBackoff = time.Minute
+)
+
+// RetryPolicy controls how Repository.Apply retries commands that were rejected by the Store
+// with a concurrency conflict.  Each attempt reloads the aggregate and re-runs the command
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the command will be applied including the
+	// first attempt; defaults to 3
+	MaxAttempts int
+
+	// Backoff is the delay before the second attempt; the delay doubles with each subsequent
+	// attempt
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between attempts; defaults to the greater of one minute and
+	// Backoff
+	MaxBackoff time.Duration
+
+	// Jitter is the fraction of each delay, between 0 and 1, that is randomized to keep
+	// competing writers from retrying in lock step
+	Jitter float64
+}
+
+// WithRetry retries Repository.Apply when the store reports a concurrency conflict
+func WithRetry(policy RetryPolicy) Option {
+	return func(r *Repository) {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = defaultMaxAttempts
+		}
+		if policy.Jitter < 0 {
+			policy.Jitter = 0
+		}
+		if policy.Jitter > 1 {
+			policy.Jitter = 1
+		}
+		r.retry = &policy
+	}
+}
+
+// delay returns how long to wait after the specified attempt (1 based) failed
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	ceiling := p.MaxBackoff
+	if ceiling <= 0 {
+		ceiling = defaultMaxBackoff
+		if p.Backoff > ceiling {
+			ceiling = p.Backoff
+		}
+	}
+
+	// stop doubling at the ceiling so large attempt counts cannot overflow the delay
+	d := p.Backoff
+	for i := 1; i < attempt && d > 0 && d < ceiling; i++ {
+		if d > ceiling/2 {
+			d = ceiling
+			break
+		}
+		d *= 2
+	}
+	if d > ceiling {
+		d = ceiling
+	}
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// applyWithRetry invokes apply until it succeeds, fails with an error other than a
+// concurrency conflict, or the policy is exhausted
+func (r *Repository) applyWithRetry(ctx context.Context, command Command) (int, error) {
+	policy := r.retry
+
+	for attempt := 1; ; attempt++ {
+		version, err := r.apply(ctx, command)
+		if err == nil || !IsConflictError(err) {
+			return version, err
+		}
+		if attempt >= policy.MaxAttempts {
+			return 0, xerrors.Errorf("unable to apply command to aggregate, %v, after %v attempt(s): %w", command.AggregateID(), attempt, err)
+		}
+
+		r.logf("Conflict applying command to aggregate, %v; retrying (attempt %v of %v)", command.AggregateID(), attempt+1, policy.MaxAttempts)
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
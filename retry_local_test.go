@@ -0,0 +1,33 @@
+package eventsource
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_delay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 1000, Backoff: time.Millisecond}
+
+	var previous time.Duration
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		d := policy.delay(attempt)
+		if d < previous {
+			t.Fatalf("got %v at attempt %v; want at least %v", d, attempt, previous)
+		}
+		if d > defaultMaxBackoff {
+			t.Fatalf("got %v at attempt %v; want at most %v", d, attempt, defaultMaxBackoff)
+		}
+		previous = d
+	}
+	if got, want := previous, defaultMaxBackoff; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	policy = RetryPolicy{Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	if got, want := policy.delay(2), 2*time.Millisecond; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := policy.delay(100), 5*time.Millisecond; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
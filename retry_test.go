@@ -0,0 +1,100 @@
+package eventsource_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// conflictingStore rejects the first n calls to SaveVersion with a concurrency conflict
+type conflictingStore struct {
+	versionedStore
+	n     int
+	calls int
+}
+
+func (s *conflictingStore) SaveVersion(ctx context.Context, aggregateID string, expectedVersion int, records ...eventsource.Record) error {
+	s.calls++
+	if s.calls <= s.n {
+		return &eventsource.ConflictError{
+			AggregateID:     aggregateID,
+			ExpectedVersion: expectedVersion,
+			ActualVersion:   expectedVersion + 1,
+		}
+	}
+	return s.versionedStore.SaveVersion(ctx, aggregateID, expectedVersion, records...)
+}
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+	cmd := &CreateEntity{CommandModel: eventsource.CommandModel{ID: "123"}}
+
+	newRepository := func(store eventsource.Store, policy eventsource.RetryPolicy) *eventsource.Repository {
+		return eventsource.New(&Entity{},
+			eventsource.WithStore(store),
+			eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{})),
+			eventsource.WithRetry(policy),
+		)
+	}
+
+	t.Run("succeeds after conflicts", func(t *testing.T) {
+		store := &conflictingStore{
+			versionedStore: eventsource.New(&Entity{}).Store().(versionedStore),
+			n:              2,
+		}
+		repo := newRepository(store, eventsource.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     time.Millisecond,
+			Jitter:      0.5,
+		})
+
+		version, err := repo.Apply(ctx, cmd)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := version, 1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := store.calls, 3; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		store := &conflictingStore{
+			versionedStore: eventsource.New(&Entity{}).Store().(versionedStore),
+			n:              5,
+		}
+		repo := newRepository(store, eventsource.RetryPolicy{MaxAttempts: 2})
+
+		_, err := repo.Apply(ctx, cmd)
+		if !eventsource.IsConflictError(err) {
+			t.Fatalf("got %v; want conflict error", err)
+		}
+		if got, want := err.Error(), "after 2 attempt(s)"; !strings.Contains(got, want) {
+			t.Fatalf("got %v; want contains %v", got, want)
+		}
+		if got, want := store.calls, 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		store := &conflictingStore{
+			versionedStore: eventsource.New(&Entity{}).Store().(versionedStore),
+			n:              5,
+		}
+		repo := newRepository(store, eventsource.RetryPolicy{MaxAttempts: 5, Backoff: time.Hour})
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := repo.Apply(ctx, cmd)
+		if got, want := err, context.DeadlineExceeded; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
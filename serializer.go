@@ -16,14 +16,38 @@ type Serializer interface {
 	UnmarshalEvent(record Record) (Event, error)
 }
 
+// EventsUnmarshaler is an optional interface that allows a Serializer to convert a single
+// Record into zero or more Events e.g. when an upcaster splits a stored event in two
+type EventsUnmarshaler interface {
+	// UnmarshalEvents converts a Record into the Events it represents
+	UnmarshalEvents(record Record) ([]Event, error)
+}
+
+// UnmarshalEvents converts the record using serializer.UnmarshalEvents when serializer
+// implements EventsUnmarshaler and serializer.UnmarshalEvent otherwise
+func UnmarshalEvents(serializer Serializer, record Record) ([]Event, error) {
+	if v, ok := serializer.(EventsUnmarshaler); ok {
+		return v.UnmarshalEvents(record)
+	}
+
+	event, err := serializer.UnmarshalEvent(record)
+	if err != nil {
+		return nil, err
+	}
+	return []Event{event}, nil
+}
+
 type jsonEvent struct {
-	Type string          `json:"t"`
-	Data json.RawMessage `json:"d"`
+	Type     string          `json:"t"`
+	Revision int             `json:"r,omitempty"`
+	Data     json.RawMessage `json:"d"`
 }
 
 // JSONSerializer provides a simple serializer implementation
 type JSONSerializer struct {
 	eventTypes map[string]reflect.Type
+	upcasters  map[upcastKey]Upcaster
+	revisions  map[string]int
 }
 
 // Bind registers the specified events with the serializer; may be called more than once
@@ -44,8 +68,9 @@ func (j *JSONSerializer) MarshalEvent(v Event) (Record, error) {
 	}
 
 	data, err = json.Marshal(jsonEvent{
-		Type: eventType,
-		Data: json.RawMessage(data),
+		Type:     eventType,
+		Revision: j.revisions[eventType],
+		Data:     json.RawMessage(data),
 	})
 	if err != nil {
 		return Record{}, xerrors.Errorf("unable to encode event: %v: %w", err, errInvalidEncoding)
@@ -57,26 +82,50 @@ func (j *JSONSerializer) MarshalEvent(v Event) (Record, error) {
 	}, nil
 }
 
-// UnmarshalEvent converts the persistent type, Record, into an Event instance
+// UnmarshalEvent converts the persistent type, Record, into an Event instance.  Returns an
+// error if the upcasters convert the record into anything other than a single event; use
+// UnmarshalEvents to decode records that may be split
 func (j *JSONSerializer) UnmarshalEvent(record Record) (Event, error) {
+	events, err := j.UnmarshalEvents(record)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) != 1 {
+		return nil, xerrors.Errorf("record at version, %v, was upcast into %v events; use UnmarshalEvents: %w", record.Version, len(events), errInvalidEncoding)
+	}
+	return events[0], nil
+}
+
+// UnmarshalEvents implements EventsUnmarshaler.  The stored event is passed through the
+// upcasters registered for its type and revision before being bound to its Go type
+func (j *JSONSerializer) UnmarshalEvents(record Record) ([]Event, error) {
 	wrapper := jsonEvent{}
 	err := json.Unmarshal(record.Data, &wrapper)
 	if err != nil {
 		return nil, xerrors.Errorf("unable to unmarshal event: %v: %w", err, errInvalidEncoding)
 	}
 
-	t, ok := j.eventTypes[wrapper.Type]
-	if !ok {
-		return nil, xerrors.Errorf("unbound event type, %v: %v: %w", wrapper.Type, err, errUnboundEventType)
+	upcast, err := j.upcast(UpcastEvent{Type: wrapper.Type, Revision: wrapper.Revision, Data: wrapper.Data}, 0)
+	if err != nil {
+		return nil, err
 	}
 
-	v := reflect.New(t).Interface()
-	err = json.Unmarshal(wrapper.Data, v)
-	if err != nil {
-		return nil, xerrors.Errorf("unable to unmarshal event data into %#v: %v: %w", v, err, errInvalidEncoding)
+	events := make([]Event, 0, len(upcast))
+	for _, item := range upcast {
+		t, ok := j.eventTypes[item.Type]
+		if !ok {
+			return nil, xerrors.Errorf("unbound event type, %v: %w", item.Type, errUnboundEventType)
+		}
+
+		v := reflect.New(t).Interface()
+		err = json.Unmarshal(item.Data, v)
+		if err != nil {
+			return nil, xerrors.Errorf("unable to unmarshal event data into %#v: %v: %w", v, err, errInvalidEncoding)
+		}
+		events = append(events, v.(Event))
 	}
 
-	return v.(Event), nil
+	return events, nil
 }
 
 // MarshalAll is a utility that marshals all the events provided into a History object
@@ -99,6 +148,8 @@ func (j *JSONSerializer) MarshalAll(events ...Event) (History, error) {
 func NewJSONSerializer(events ...Event) *JSONSerializer {
 	serializer := &JSONSerializer{
 		eventTypes: map[string]reflect.Type{},
+		upcasters:  map[upcastKey]Upcaster{},
+		revisions:  map[string]int{},
 	}
 	serializer.Bind(events...)
 
@@ -72,3 +72,15 @@ func TestJSONSerializer_MarshalAll(t *testing.T) {
 		t.Fatalf("got %v; want %v", got, want)
 	}
 }
+
+func TestJSONSerializer_UnboundEventType(t *testing.T) {
+	record, err := eventsource.NewJSONSerializer().MarshalEvent(EntitySetName{})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	_, err = eventsource.NewJSONSerializer().UnmarshalEvent(record)
+	if !eventsource.IsUnboundEventTypeError(err) {
+		t.Fatalf("got %v; want unbound event type error", err)
+	}
+}
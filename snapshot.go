@@ -0,0 +1,222 @@
+package eventsource
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Snapshot captures the state of an aggregate as of a specific version so that the aggregate
+// can be hydrated without replaying its entire history
+type Snapshot struct {
+	// AggregateID contains the id of the aggregate
+	AggregateID string
+
+	// Version contains the version of the last event folded into the snapshot
+	Version int
+
+	// SchemaVersion contains the schema version of the aggregate when the snapshot was taken;
+	// see SchemaVersioner
+	SchemaVersion int
+
+	// Format identifies how Data was serialized e.g. SnapshotFormatJSON
+	Format string
+
+	// Data contains the aggregate in serialized form
+	Data []byte
+}
+
+// SnapshotFormatJSON identifies snapshots serialized with encoding/json
+const SnapshotFormatJSON = "json"
+
+// SchemaVersioner is an optional interface that an Aggregate can implement to declare the
+// version of its structure.  Whenever the shape of the aggregate changes, the schema version
+// should be incremented; snapshots taken with a different schema version are migrated with the
+// SnapshotMigrator, if one was provided, or discarded and rebuilt from the full history.
+// Aggregates that do not implement SchemaVersioner have a schema version of 0
+type SchemaVersioner interface {
+	// SchemaVersion returns the current schema version of the aggregate
+	SchemaVersion() int
+}
+
+// SnapshotMigrator converts a snapshot taken with an older schema version into one compatible
+// with the current schemaVersion.  When the migrator returns an error, or a snapshot whose
+// SchemaVersion differs from schemaVersion, the snapshot is discarded
+type SnapshotMigrator func(snapshot Snapshot, schemaVersion int) (Snapshot, error)
+
+// SnapshotStore provides an abstraction for the Repository to save and load snapshots
+type SnapshotStore interface {
+	// SaveSnapshot saves the snapshot provided, replacing any earlier snapshot of the aggregate
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) error
+
+	// LoadSnapshot returns the latest snapshot of the aggregate.  Returns an error detectable
+	// with IsNotFoundError when no snapshot exists
+	LoadSnapshot(ctx context.Context, aggregateID string) (Snapshot, error)
+}
+
+// SnapshotPolicy decides whether a snapshot should be taken after the aggregate was saved at
+// version.  snapshotVersion contains the version of the snapshot the aggregate was loaded from,
+// or 0 if none was used
+type SnapshotPolicy func(snapshotVersion, version int) bool
+
+// EveryNEvents returns a SnapshotPolicy that takes a snapshot once n or more events have been
+// saved since the previous snapshot
+func EveryNEvents(n int) SnapshotPolicy {
+	return func(snapshotVersion, version int) bool {
+		return version-snapshotVersion >= n
+	}
+}
+
+// WithSnapshots enables snapshots.  Snapshots are taken by Apply according to the policy
+// provided and Load will replay only those events recorded after the latest snapshot.
+// Aggregates are serialized to snapshots using encoding/json so the aggregate must be able to
+// round trip its state through json.Marshal and json.Unmarshal
+func WithSnapshots(store SnapshotStore, policy SnapshotPolicy) Option {
+	return func(r *Repository) {
+		r.snapshots = store
+		r.policy = policy
+	}
+}
+
+// WithSnapshotMigrator specifies how snapshots taken with an older schema version should be
+// migrated rather than discarded
+func WithSnapshotMigrator(migrator SnapshotMigrator) Option {
+	return func(r *Repository) {
+		r.migrator = migrator
+	}
+}
+
+// schemaVersion returns the schema version declared by the aggregate
+func schemaVersion(aggregate Aggregate) int {
+	if v, ok := aggregate.(SchemaVersioner); ok {
+		return v.SchemaVersion()
+	}
+	return 0
+}
+
+// restoreSnapshot attempts to hydrate a new aggregate from the latest snapshot.  Snapshots are
+// an optimization so failure to restore a snapshot falls back to replaying the full history
+//
+// Returns the aggregate, the version of the snapshot, whether the snapshot was restored and
+// whether the stored snapshot is stale and should be replaced
+func (r *Repository) restoreSnapshot(ctx context.Context, aggregateID string) (Aggregate, int, bool, bool) {
+	if r.snapshots == nil {
+		return nil, 0, false, false
+	}
+
+	snapshot, err := r.snapshots.LoadSnapshot(ctx, aggregateID)
+	if err != nil {
+		if !IsNotFoundError(err) {
+			r.logf("Unable to load snapshot for aggregate id, %v: %v", aggregateID, err)
+		}
+		return nil, 0, false, false
+	}
+
+	aggregate := r.newAggregate()
+	current := schemaVersion(aggregate)
+	migrated := false
+
+	if snapshot.Format != SnapshotFormatJSON {
+		r.logf("Discarding snapshot for aggregate id, %v: unsupported format, %v", aggregateID, snapshot.Format)
+		return nil, 0, false, true
+	}
+
+	if snapshot.SchemaVersion != current {
+		if r.migrator == nil {
+			r.logf("Discarding snapshot for aggregate id, %v: schema version %v; want %v", aggregateID, snapshot.SchemaVersion, current)
+			return nil, 0, false, true
+		}
+
+		snapshot, err = r.migrator(snapshot, current)
+		if err != nil {
+			r.logf("Discarding snapshot for aggregate id, %v: unable to migrate: %v", aggregateID, err)
+			return nil, 0, false, true
+		}
+		if snapshot.SchemaVersion != current || snapshot.Format != SnapshotFormatJSON {
+			r.logf("Discarding snapshot for aggregate id, %v: migrated to schema version %v; want %v", aggregateID, snapshot.SchemaVersion, current)
+			return nil, 0, false, true
+		}
+		migrated = true
+	}
+
+	if err := json.Unmarshal(snapshot.Data, aggregate); err != nil {
+		r.logf("Unable to restore snapshot for aggregate id, %v: %v", aggregateID, err)
+		return nil, 0, false, true
+	}
+
+	r.logf("Restored snapshot for aggregate id, %v, at version %v", aggregateID, snapshot.Version)
+	if migrated {
+		r.saveSnapshot(ctx, aggregateID, aggregate, snapshot.Version)
+	}
+
+	return aggregate, snapshot.Version, true, false
+}
+
+// takeSnapshot saves a snapshot of the aggregate when required by the policy
+func (r *Repository) takeSnapshot(ctx context.Context, aggregateID string, aggregate Aggregate, snapshotVersion, version int) {
+	if r.snapshots == nil || r.policy == nil || !r.policy(snapshotVersion, version) {
+		return
+	}
+
+	r.saveSnapshot(ctx, aggregateID, aggregate, version)
+}
+
+// saveSnapshot saves a snapshot of the aggregate as of the version specified.  Snapshots are
+// taken after the events have been committed so errors are logged rather than returned
+func (r *Repository) saveSnapshot(ctx context.Context, aggregateID string, aggregate Aggregate, version int) {
+	data, err := json.Marshal(aggregate)
+	if err != nil {
+		r.logf("Unable to serialize snapshot for aggregate id, %v: %v", aggregateID, err)
+		return
+	}
+
+	snapshot := Snapshot{
+		AggregateID:   aggregateID,
+		Version:       version,
+		SchemaVersion: schemaVersion(aggregate),
+		Format:        SnapshotFormatJSON,
+		Data:          data,
+	}
+	if err := r.snapshots.SaveSnapshot(ctx, snapshot); err != nil {
+		r.logf("Unable to save snapshot for aggregate id, %v: %v", aggregateID, err)
+		return
+	}
+
+	r.logf("Saved snapshot for aggregate id, %v, at version %v", aggregateID, version)
+}
+
+// MemorySnapshotStore provides an in-memory implementation of SnapshotStore suitable for testing
+type MemorySnapshotStore struct {
+	mux       sync.Mutex
+	snapshots map[string]Snapshot
+}
+
+// NewMemorySnapshotStore returns a new, empty MemorySnapshotStore
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{
+		snapshots: map[string]Snapshot{},
+	}
+}
+
+// SaveSnapshot implements SnapshotStore
+func (m *MemorySnapshotStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.snapshots[snapshot.AggregateID] = snapshot
+	return nil
+}
+
+// LoadSnapshot implements SnapshotStore
+func (m *MemorySnapshotStore) LoadSnapshot(ctx context.Context, aggregateID string) (Snapshot, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	snapshot, ok := m.snapshots[aggregateID]
+	if !ok {
+		return Snapshot{}, xerrors.Errorf("no snapshot found for aggregate id, %v: %w", aggregateID, errAggregateNotFound)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,214 @@
+package eventsource_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// recordingStore captures the fromVersion of each call to Load
+type recordingStore struct {
+	versionedStore
+	fromVersions []int
+}
+
+func (s *recordingStore) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	s.fromVersions = append(s.fromVersions, fromVersion)
+	return s.versionedStore.Load(ctx, aggregateID, fromVersion, toVersion)
+}
+
+func TestEveryNEvents(t *testing.T) {
+	policy := eventsource.EveryNEvents(3)
+	if policy(0, 2) {
+		t.Fatalf("got true; want false")
+	}
+	if !policy(0, 3) {
+		t.Fatalf("got false; want true")
+	}
+	if policy(3, 5) {
+		t.Fatalf("got true; want false")
+	}
+}
+
+func TestWithSnapshots(t *testing.T) {
+	ctx := context.Background()
+	id := "abc"
+	serializer := eventsource.NewJSONSerializer(EntityCreated{})
+	store := &recordingStore{
+		versionedStore: eventsource.New(&Entity{}).Store().(versionedStore),
+	}
+	snapshots := eventsource.NewMemorySnapshotStore()
+	repo := eventsource.New(&Entity{},
+		eventsource.WithStore(store),
+		eventsource.WithSerializer(serializer),
+		eventsource.WithSnapshots(snapshots, eventsource.EveryNEvents(2)),
+	)
+
+	cmd := &CreateEntity{CommandModel: eventsource.CommandModel{ID: id}}
+	for i := 0; i < 3; i++ {
+		if _, err := repo.Apply(ctx, cmd); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+
+	snapshot, err := snapshots.LoadSnapshot(ctx, id)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := snapshot.Version, 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	store.fromVersions = nil
+	aggregate, version, err := repo.Load(ctx, id)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := version, 3; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := store.fromVersions, []int{3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// And the aggregate should match one hydrated from the full history
+	expected, _, err := eventsource.New(&Entity{},
+		eventsource.WithStore(store),
+		eventsource.WithSerializer(serializer),
+	).Load(ctx, id)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := aggregate.(*Entity), expected.(*Entity); got.Version != want.Version || !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestWithSnapshots_NoNewEvents(t *testing.T) {
+	ctx := context.Background()
+	id := "abc"
+	repo := eventsource.New(&Entity{},
+		eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{})),
+		eventsource.WithSnapshots(eventsource.NewMemorySnapshotStore(), eventsource.EveryNEvents(1)),
+	)
+
+	cmd := &CreateEntity{CommandModel: eventsource.CommandModel{ID: id}}
+	if _, err := repo.Apply(ctx, cmd); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	aggregate, version, err := repo.Load(ctx, id)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := version, 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := aggregate.(*Entity).ID, id; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestMemorySnapshotStore_NotFound(t *testing.T) {
+	_, err := eventsource.NewMemorySnapshotStore().LoadSnapshot(context.Background(), "does-not-exist")
+	if !eventsource.IsNotFoundError(err) {
+		t.Fatalf("got %v; want not found", err)
+	}
+}
+
+// EntityV2 is an Entity whose snapshot schema has changed
+type EntityV2 struct {
+	Entity
+}
+
+func (item *EntityV2) SchemaVersion() int {
+	return 2
+}
+
+func TestSnapshotSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	id := "abc"
+
+	setup := func(t *testing.T, opts ...eventsource.Option) (*eventsource.Repository, *eventsource.MemorySnapshotStore) {
+		snapshots := eventsource.NewMemorySnapshotStore()
+		opts = append([]eventsource.Option{
+			eventsource.WithSerializer(eventsource.NewJSONSerializer(EntityCreated{}, EntityNameSet{})),
+			eventsource.WithSnapshots(snapshots, eventsource.EveryNEvents(10)),
+		}, opts...)
+		repo := eventsource.New(&EntityV2{}, opts...)
+
+		err := repo.Save(ctx,
+			&EntityCreated{Model: eventsource.Model{ID: id, Version: 1}},
+			&EntityNameSet{Model: eventsource.Model{ID: id, Version: 2}, Name: "current"},
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		err = snapshots.SaveSnapshot(ctx, eventsource.Snapshot{
+			AggregateID:   id,
+			Version:       2,
+			SchemaVersion: 1,
+			Format:        eventsource.SnapshotFormatJSON,
+			Data:          []byte(`{"ID":"abc","Version":2,"Title":"stale"}`),
+		})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		return repo, snapshots
+	}
+
+	t.Run("stale snapshot is rebuilt", func(t *testing.T) {
+		repo, snapshots := setup(t)
+
+		aggregate, _, err := repo.Load(ctx, id)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := aggregate.(*EntityV2).Name, "current"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		snapshot, err := snapshots.LoadSnapshot(ctx, id)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := snapshot.SchemaVersion, 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := snapshot.Version, 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("stale snapshot is migrated", func(t *testing.T) {
+		migrator := func(snapshot eventsource.Snapshot, schemaVersion int) (eventsource.Snapshot, error) {
+			snapshot.Data = []byte(`{"ID":"abc","Version":2,"Name":"migrated"}`)
+			snapshot.SchemaVersion = schemaVersion
+			return snapshot, nil
+		}
+		repo, snapshots := setup(t, eventsource.WithSnapshotMigrator(migrator))
+
+		aggregate, version, err := repo.Load(ctx, id)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := version, 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := aggregate.(*EntityV2).Name, "migrated"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		snapshot, err := snapshots.LoadSnapshot(ctx, id)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := snapshot.SchemaVersion, 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
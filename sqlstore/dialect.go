@@ -0,0 +1,125 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect describes the differences between the databases supported by Store
+type Dialect interface {
+	// CreateTable returns the statements required to create the events table along with its
+	// sequence table, <table>_sequence, holding the single row from which offsets are allocated
+	CreateTable(table string) []string
+
+	// Placeholder returns the bind parameter for the nth, 1 based, argument of a statement
+	Placeholder(n int) string
+
+	// IsUniqueViolation returns true if err was caused by a unique constraint violation
+	IsUniqueViolation(err error) bool
+}
+
+var (
+	// Postgres supports PostgreSQL e.g. github.com/lib/pq or github.com/jackc/pgx
+	Postgres Dialect = postgres{}
+
+	// MySQL supports MySQL and MariaDB e.g. github.com/go-sql-driver/mysql
+	MySQL Dialect = mysql{}
+
+	// SQLite supports SQLite e.g. github.com/mattn/go-sqlite3 or modernc.org/sqlite
+	SQLite Dialect = sqlite{}
+)
+
+// unqualified returns the table name without its schema or database e.g. events for
+// audit.events; constraint and index names may not be qualified
+func unqualified(table string) string {
+	return table[strings.LastIndex(table, ".")+1:]
+}
+
+type postgres struct{}
+
+func (postgres) CreateTable(table string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+	id           BIGINT       PRIMARY KEY,
+	aggregate_id VARCHAR(255) NOT NULL,
+	version      INTEGER      NOT NULL,
+	data         BYTEA        NOT NULL,
+	metadata     TEXT,
+	CONSTRAINT %v_aggregate_version UNIQUE (aggregate_id, version)
+)`, table, unqualified(table)),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v_sequence (
+	id      INTEGER PRIMARY KEY,
+	last_id BIGINT  NOT NULL
+)`, table),
+		fmt.Sprintf(`INSERT INTO %v_sequence (id, last_id) VALUES (1, 0) ON CONFLICT DO NOTHING`, table),
+	}
+}
+
+func (postgres) Placeholder(n int) string {
+	return fmt.Sprintf("$%v", n)
+}
+
+func (postgres) IsUniqueViolation(err error) bool {
+	if v, ok := err.(interface{ SQLState() string }); ok {
+		return v.SQLState() == "23505"
+	}
+	return strings.Contains(err.Error(), "23505") ||
+		strings.Contains(err.Error(), "duplicate key value violates unique constraint")
+}
+
+type mysql struct{}
+
+func (mysql) CreateTable(table string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+	id           BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+	aggregate_id VARCHAR(255)    NOT NULL,
+	version      INT             NOT NULL,
+	data         LONGBLOB        NOT NULL,
+	metadata     TEXT,
+	UNIQUE KEY %v_aggregate_version (aggregate_id, version)
+) ENGINE=InnoDB`, table, unqualified(table)),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v_sequence (
+	id      INT             NOT NULL PRIMARY KEY,
+	last_id BIGINT UNSIGNED NOT NULL
+) ENGINE=InnoDB`, table),
+		fmt.Sprintf(`INSERT IGNORE INTO %v_sequence (id, last_id) VALUES (1, 0)`, table),
+	}
+}
+
+func (mysql) Placeholder(int) string {
+	return "?"
+}
+
+func (mysql) IsUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "Error 1062") ||
+		strings.Contains(err.Error(), "Duplicate entry")
+}
+
+type sqlite struct{}
+
+func (sqlite) CreateTable(table string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+	id           INTEGER PRIMARY KEY,
+	aggregate_id TEXT    NOT NULL,
+	version      INTEGER NOT NULL,
+	data         BLOB    NOT NULL,
+	metadata     TEXT,
+	UNIQUE (aggregate_id, version)
+)`, table),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v_sequence (
+	id      INTEGER PRIMARY KEY,
+	last_id INTEGER NOT NULL
+)`, table),
+		fmt.Sprintf(`INSERT OR IGNORE INTO %v_sequence (id, last_id) VALUES (1, 0)`, table),
+	}
+}
+
+func (sqlite) Placeholder(int) string {
+	return "?"
+}
+
+func (sqlite) IsUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
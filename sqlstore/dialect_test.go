@@ -0,0 +1,36 @@
+package sqlstore_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource/sqlstore"
+)
+
+func TestDialect_QualifiedTable(t *testing.T) {
+	testCases := map[string]struct {
+		Dialect sqlstore.Dialect
+		Want    string
+	}{
+		"postgres": {Dialect: sqlstore.Postgres, Want: "CONSTRAINT events_aggregate_version UNIQUE"},
+		"mysql":    {Dialect: sqlstore.MySQL, Want: "UNIQUE KEY events_aggregate_version ("},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			stmt := strings.Join(tc.Dialect.CreateTable("audit.events"), ";\n")
+			if !strings.Contains(stmt, "CREATE TABLE IF NOT EXISTS audit.events (") {
+				t.Fatalf("got %v; want statement creating audit.events", stmt)
+			}
+			if !strings.Contains(stmt, tc.Want) {
+				t.Fatalf("got %v; want statement containing %v", stmt, tc.Want)
+			}
+			if strings.Contains(stmt, "audit.events_aggregate_version") {
+				t.Fatalf("got %v; want constraint named after the unqualified table", stmt)
+			}
+			if !strings.Contains(stmt, "CREATE TABLE IF NOT EXISTS audit.events_sequence (") {
+				t.Fatalf("got %v; want sequence table in the same schema as audit.events", stmt)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/eventsource-ecosystem/eventsource/sqlstore
+
+go 1.21
+
+require github.com/eventsource-ecosystem/eventsource v0.0.0-00010101000000-000000000000
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.33
+	golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7 // indirect
+)
+
+replace github.com/eventsource-ecosystem/eventsource => ..
@@ -0,0 +1,310 @@
+// Package sqlstore provides an eventsource.Store backed by database/sql.
+//
+// Records are stored in a single table with a unique constraint on (aggregate_id, version) so
+// that concurrent writers cannot save the same version twice.  The id of each row provides the
+// global offset used by the eventsource.StreamReader implementation.  Ids are allocated from a
+// sequence row updated in the same transaction as the insert; the row lock serializes writers
+// so that records commit in offset order and a rolled back save leaves no gap in the stream.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const defaultTable = "events"
+
+var reTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// AggregateWriter allows the aggregate to be persisted in the same transaction as its records
+// e.g. to maintain a strongly consistent read model
+type AggregateWriter func(ctx context.Context, tx *sql.Tx, input eventsource.SaveAggregateInput) error
+
+// Option provides functional configuration for a *Store
+type Option func(*Store)
+
+// WithTable specifies the name of the table records will be stored in; defaults to events
+func WithTable(table string) Option {
+	return func(s *Store) {
+		s.table = table
+	}
+}
+
+// WithAggregateWriter specifies a func to be called within the transaction used by SaveAggregate
+func WithAggregateWriter(writer AggregateWriter) Option {
+	return func(s *Store) {
+		s.writer = writer
+	}
+}
+
+// Store provides a database/sql implementation of eventsource.Store
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	writer  AggregateWriter
+
+	allocateSQL string
+	sequenceSQL string
+	insertSQL   string
+	loadSQL     string
+	existsSQL   string
+	versionSQL  string
+	readSQL     string
+}
+
+// New returns a Store that persists records to db using the dialect specified
+func New(db *sql.DB, dialect Dialect, opts ...Option) (*Store, error) {
+	s := &Store{
+		db:      db,
+		dialect: dialect,
+		table:   defaultTable,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if !reTable.MatchString(s.table) {
+		return nil, fmt.Errorf("invalid table name, %v", s.table)
+	}
+
+	p := dialect.Placeholder
+	s.allocateSQL = fmt.Sprintf("UPDATE %v_sequence SET last_id = last_id + %v WHERE id = 1", s.table, p(1))
+	s.sequenceSQL = fmt.Sprintf("SELECT last_id FROM %v_sequence WHERE id = 1", s.table)
+	s.insertSQL = fmt.Sprintf("INSERT INTO %v (id, aggregate_id, version, data, metadata) VALUES (%v, %v, %v, %v, %v)", s.table, p(1), p(2), p(3), p(4), p(5))
+	s.loadSQL = fmt.Sprintf("SELECT version, data, metadata FROM %v WHERE aggregate_id = %v AND version >= %v AND version <= %v ORDER BY version", s.table, p(1), p(2), p(3))
+	s.existsSQL = fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE aggregate_id = %v", s.table, p(1))
+	s.versionSQL = fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %v WHERE aggregate_id = %v", s.table, p(1))
+	s.readSQL = fmt.Sprintf("SELECT id, aggregate_id, version, data, metadata FROM %v WHERE id >= %v ORDER BY id LIMIT %v", s.table, p(1), p(2))
+
+	return s, nil
+}
+
+// CreateTable creates the events table and its sequence table if they do not already exist
+func (s *Store) CreateTable(ctx context.Context) error {
+	for _, stmt := range s.dialect.CreateTable(s.table) {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("unable to create table, %v: %v", s.table, err)
+		}
+	}
+	return nil
+}
+
+// Save implements eventsource.Store
+func (s *Store) Save(ctx context.Context, aggregateID string, records ...eventsource.Record) error {
+	return s.save(ctx, aggregateID, -1, records, nil)
+}
+
+// SaveVersion implements eventsource.VersionedStore
+func (s *Store) SaveVersion(ctx context.Context, aggregateID string, expectedVersion int, records ...eventsource.Record) error {
+	return s.save(ctx, aggregateID, expectedVersion, records, nil)
+}
+
+// SaveAggregate implements eventsource.AggregateSaver.  When an AggregateWriter was provided,
+// it will be called within the same transaction used to insert the records
+func (s *Store) SaveAggregate(ctx context.Context, input eventsource.SaveAggregateInput) error {
+	var fn func(tx *sql.Tx) error
+	if s.writer != nil {
+		fn = func(tx *sql.Tx) error {
+			return s.writer(ctx, tx, input)
+		}
+	}
+	return s.save(ctx, input.AggregateID, input.ExpectedVersion, input.Records, fn)
+}
+
+// save inserts the records within a transaction.  When expectedVersion is non-negative, the
+// current version of the aggregate must match it
+func (s *Store) save(ctx context.Context, aggregateID string, expectedVersion int, records []eventsource.Record, fn func(tx *sql.Tx) error) error {
+	if len(records) == 0 && expectedVersion < 0 && fn == nil {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// allocate offsets before checking the version so that concurrent saves of the aggregate
+	// are serialized by the sequence row lock
+	var offset int64
+	if len(records) > 0 {
+		if offset, err = s.allocate(ctx, tx, len(records)); err != nil {
+			return err
+		}
+	}
+
+	if expectedVersion >= 0 {
+		var actualVersion int
+		if err := tx.QueryRowContext(ctx, s.versionSQL, aggregateID).Scan(&actualVersion); err != nil {
+			return fmt.Errorf("unable to query version of aggregate, %v: %v", aggregateID, err)
+		}
+		if actualVersion != expectedVersion {
+			return &eventsource.ConflictError{
+				AggregateID:     aggregateID,
+				ExpectedVersion: expectedVersion,
+				ActualVersion:   actualVersion,
+			}
+		}
+	}
+
+	for i, record := range records {
+		metadata, err := encodeMetadata(record.Metadata)
+		if err != nil {
+			return fmt.Errorf("unable to encode metadata for aggregate, %v: %v", aggregateID, err)
+		}
+		if _, err := tx.ExecContext(ctx, s.insertSQL, offset+int64(i), aggregateID, record.Version, record.Data, metadata); err != nil {
+			if s.dialect.IsUniqueViolation(err) {
+				tx.Rollback()
+				return s.conflict(ctx, aggregateID, expectedVersion, records)
+			}
+			return fmt.Errorf("unable to insert record for aggregate, %v: %v", aggregateID, err)
+		}
+	}
+
+	if fn != nil {
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		if s.dialect.IsUniqueViolation(err) {
+			return s.conflict(ctx, aggregateID, expectedVersion, records)
+		}
+		return fmt.Errorf("unable to commit records for aggregate, %v: %v", aggregateID, err)
+	}
+
+	return nil
+}
+
+// allocate reserves n offsets from the sequence row and returns the first.  The row remains
+// locked until tx completes
+func (s *Store) allocate(ctx context.Context, tx *sql.Tx, n int) (int64, error) {
+	if _, err := tx.ExecContext(ctx, s.allocateSQL, n); err != nil {
+		return 0, fmt.Errorf("unable to allocate stream offsets: %v", err)
+	}
+
+	var lastID int64
+	if err := tx.QueryRowContext(ctx, s.sequenceSQL).Scan(&lastID); err != nil {
+		return 0, fmt.Errorf("unable to allocate stream offsets: %v", err)
+	}
+
+	return lastID - int64(n) + 1, nil
+}
+
+// conflict returns a *eventsource.ConflictError describing a unique constraint violation
+func (s *Store) conflict(ctx context.Context, aggregateID string, expectedVersion int, records []eventsource.Record) error {
+	if expectedVersion < 0 {
+		expectedVersion = records[0].Version - 1
+	}
+
+	var actualVersion int
+	if err := s.db.QueryRowContext(ctx, s.versionSQL, aggregateID).Scan(&actualVersion); err != nil {
+		return fmt.Errorf("unable to query version of aggregate, %v: %v", aggregateID, err)
+	}
+
+	return &eventsource.ConflictError{
+		AggregateID:     aggregateID,
+		ExpectedVersion: expectedVersion,
+		ActualVersion:   actualVersion,
+	}
+}
+
+// Load implements eventsource.Store
+func (s *Store) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	if toVersion == 0 {
+		toVersion = math.MaxInt32
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.loadSQL, aggregateID, fromVersion, toVersion)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load aggregate, %v: %v", aggregateID, err)
+	}
+	defer rows.Close()
+
+	history := eventsource.History{}
+	for rows.Next() {
+		record := eventsource.Record{}
+		var metadata sql.NullString
+		if err := rows.Scan(&record.Version, &record.Data, &metadata); err != nil {
+			return nil, fmt.Errorf("unable to scan record for aggregate, %v: %v", aggregateID, err)
+		}
+		if record.Metadata, err = decodeMetadata(metadata); err != nil {
+			return nil, fmt.Errorf("unable to decode metadata for aggregate, %v: %v", aggregateID, err)
+		}
+		history = append(history, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to load aggregate, %v: %v", aggregateID, err)
+	}
+
+	if len(history) == 0 {
+		var count int
+		if err := s.db.QueryRowContext(ctx, s.existsSQL, aggregateID).Scan(&count); err != nil {
+			return nil, fmt.Errorf("unable to load aggregate, %v: %v", aggregateID, err)
+		}
+		if count == 0 {
+			return nil, eventsource.NewNotFoundError(aggregateID)
+		}
+	}
+
+	return history, nil
+}
+
+// Read implements eventsource.StreamReader.  Offsets correspond to the id of each row
+func (s *Store) Read(ctx context.Context, startingOffset uint64, recordCount int) ([]eventsource.StreamRecord, error) {
+	rows, err := s.db.QueryContext(ctx, s.readSQL, int64(startingOffset), recordCount)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read stream from offset, %v: %v", startingOffset, err)
+	}
+	defer rows.Close()
+
+	var records []eventsource.StreamRecord
+	for rows.Next() {
+		record := eventsource.StreamRecord{}
+		var metadata sql.NullString
+		if err := rows.Scan(&record.Offset, &record.AggregateID, &record.Version, &record.Data, &metadata); err != nil {
+			return nil, fmt.Errorf("unable to scan stream record: %v", err)
+		}
+		if record.Metadata, err = decodeMetadata(metadata); err != nil {
+			return nil, fmt.Errorf("unable to decode metadata of stream record at offset, %v: %v", record.Offset, err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read stream from offset, %v: %v", startingOffset, err)
+	}
+
+	return records, nil
+}
+
+// encodeMetadata returns the json encoded metadata or nil, stored as NULL, when there is none
+func encodeMetadata(metadata map[string]string) (interface{}, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// decodeMetadata reverses encodeMetadata
+func decodeMetadata(s sql.NullString) (map[string]string, error) {
+	if !s.Valid || s.String == "" {
+		return nil, nil
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(s.String), &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -0,0 +1,184 @@
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/sqlstore"
+	"github.com/eventsource-ecosystem/eventsource/storetest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newStore(t *testing.T, opts ...sqlstore.Option) (*sqlstore.Store, *sql.DB, func()) {
+	dir, err := os.MkdirTemp("", "sqlstore")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "events.db"))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	store, err := sqlstore.New(db, sqlstore.SQLite, opts...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.CreateTable(context.Background()); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	return store, db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) eventsource.Store {
+		store, _, cleanup := newStore(t)
+		t.Cleanup(cleanup)
+		return store
+	})
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store, _, cleanup := newStore(t)
+	defer cleanup()
+
+	err := store.Save(ctx, "a",
+		eventsource.Record{Version: 1, Data: []byte("a1")},
+		eventsource.Record{Version: 2, Data: []byte("a2")},
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.SaveVersion(ctx, "b", 0, eventsource.Record{Version: 1, Data: []byte("b1")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	history, err := store.Load(ctx, "a", 2, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(history), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := string(history[0].Data), "a2"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	records, err := store.Read(ctx, 2, 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(records), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := records[1].AggregateID, "b"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := records[1].Offset, uint64(3); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_NotFound(t *testing.T) {
+	store, _, cleanup := newStore(t)
+	defer cleanup()
+
+	_, err := store.Load(context.Background(), "does-not-exist", 0, 0)
+	if !eventsource.IsNotFoundError(err) {
+		t.Fatalf("got %v; want not found", err)
+	}
+}
+
+func TestStore_Conflict(t *testing.T) {
+	ctx := context.Background()
+	store, _, cleanup := newStore(t)
+	defer cleanup()
+
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 1, Data: []byte("a1")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	t.Run("expected version", func(t *testing.T) {
+		err := store.SaveVersion(ctx, "a", 0, eventsource.Record{Version: 1, Data: []byte("a1")})
+		if !eventsource.IsConflictError(err) {
+			t.Fatalf("got %v; want conflict", err)
+		}
+	})
+
+	t.Run("unique constraint", func(t *testing.T) {
+		err := store.Save(ctx, "a", eventsource.Record{Version: 1, Data: []byte("a1")})
+		if !eventsource.IsConflictError(err) {
+			t.Fatalf("got %v; want conflict", err)
+		}
+	})
+}
+
+func TestStore_ReadAfterConflict(t *testing.T) {
+	ctx := context.Background()
+	store, _, cleanup := newStore(t)
+	defer cleanup()
+
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 1, Data: []byte("a1")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 1, Data: []byte("a1")}); !eventsource.IsConflictError(err) {
+		t.Fatalf("got %v; want conflict", err)
+	}
+	if err := store.Save(ctx, "b", eventsource.Record{Version: 1, Data: []byte("b1")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// offsets allocated by the failed save were rolled back along with it
+	records, err := store.Read(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(records), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for i, record := range records {
+		if got, want := record.Offset, uint64(i+1); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
+
+func TestStore_SaveAggregate(t *testing.T) {
+	ctx := context.Background()
+	var captured []string
+	writer := func(ctx context.Context, tx *sql.Tx, input eventsource.SaveAggregateInput) error {
+		captured = append(captured, input.AggregateID)
+		return nil
+	}
+
+	store, _, cleanup := newStore(t, sqlstore.WithAggregateWriter(writer))
+	defer cleanup()
+
+	err := store.SaveAggregate(ctx, eventsource.SaveAggregateInput{
+		AggregateID: "a",
+		Records:     []eventsource.Record{{Version: 1, Data: []byte("a1")}},
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(captured), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestNew_InvalidTable(t *testing.T) {
+	_, err := sqlstore.New(nil, sqlstore.Postgres, sqlstore.WithTable("events; DROP TABLE users"))
+	if err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
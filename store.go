@@ -2,10 +2,11 @@ package eventsource
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"sort"
 	"sync"
-
-	"golang.org/x/xerrors"
 )
 
 // Record provides the serialized representation of the event
@@ -15,6 +16,10 @@ type Record struct {
 
 	// Data contains the event in serialized form
 	Data []byte
+
+	// Metadata contains contextual information about the event such as the actor that issued
+	// the command and the correlation and causation ids; see ContextWithMetadata
+	Metadata map[string]string
 }
 
 // History represents
@@ -46,6 +51,16 @@ type Store interface {
 	Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (History, error)
 }
 
+// VersionedStore is an optional interface that allows a Store to provide optimistic concurrency
+// control.  When the Store implements VersionedStore, Repository.Apply will call SaveVersion
+// rather than Save
+type VersionedStore interface {
+	// SaveVersion saves the provided serialized records only if the latest version stored for
+	// the aggregate equals expectedVersion; use 0 when the aggregate is not expected to exist.
+	// Returns a *ConflictError if the versions do not match
+	SaveVersion(ctx context.Context, aggregateID string, expectedVersion int, records ...Record) error
+}
+
 // Deprecated - use AggregateSaver  instead
 //
 // StoreAggregate provides an alternative to Store that allows the aggregate to
@@ -58,10 +73,11 @@ type StoreAggregate interface {
 
 // SaveAggregateInput includes additional fields that simplify the saving of Aggregates
 type SaveAggregateInput struct {
-	AggregateID string    // AggregateID
-	Aggregate   Aggregate // Aggregate (with all the events applied)
-	Events      []Event   // Events that were applied
-	Records     []Record  // Records to be persisted e.g. the serialized events
+	AggregateID     string    // AggregateID
+	Aggregate       Aggregate // Aggregate (with all the events applied)
+	Events          []Event   // Events that were applied
+	Records         []Record  // Records to be persisted e.g. the serialized events
+	ExpectedVersion int       // ExpectedVersion of the aggregate prior to the events; savers should return a *ConflictError on mismatch
 }
 
 // AggregateSaver provides a custom interface to save aggregates
@@ -69,45 +85,236 @@ type AggregateSaver interface {
 	SaveAggregate(ctx context.Context, input SaveAggregateInput) error
 }
 
-// memoryStore provides an in-memory implementation of Store
-type memoryStore struct {
-	mux        *sync.Mutex
+// MemoryStore provides a goroutine safe, in-memory implementation of Store, VersionedStore,
+// StreamReader and StreamNotifier.  Records are assigned a global offset, starting at 1, in the
+// order they are saved
+type MemoryStore struct {
+	mux        sync.Mutex
 	eventsByID map[string]History
+	stream     []StreamRecord
+	notify     chan struct{}
 }
 
-func newMemoryStore() *memoryStore {
-	return &memoryStore{
-		mux:        &sync.Mutex{},
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
 		eventsByID: map[string]History{},
 	}
 }
 
-func (m *memoryStore) Save(ctx context.Context, aggregateID string, records ...Record) error {
+// Save implements Store
+func (m *MemoryStore) Save(ctx context.Context, aggregateID string, records ...Record) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.save(aggregateID, records...)
+	return nil
+}
+
+// SaveVersion implements VersionedStore
+func (m *MemoryStore) SaveVersion(ctx context.Context, aggregateID string, expectedVersion int, records ...Record) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	actualVersion := 0
+	if history := m.eventsByID[aggregateID]; len(history) > 0 {
+		actualVersion = history[len(history)-1].Version
+	}
+	if actualVersion != expectedVersion {
+		return &ConflictError{
+			AggregateID:     aggregateID,
+			ExpectedVersion: expectedVersion,
+			ActualVersion:   actualVersion,
+		}
+	}
+
+	m.save(aggregateID, records...)
+	return nil
+}
+
+// save appends copies of the records to both the aggregate history and the stream; callers
+// must hold the lock
+func (m *MemoryStore) save(aggregateID string, records ...Record) {
 	if _, ok := m.eventsByID[aggregateID]; !ok {
 		m.eventsByID[aggregateID] = History{}
 	}
 
-	history := append(m.eventsByID[aggregateID], records...)
-	sort.Sort(history)
+	history := m.eventsByID[aggregateID]
+	for _, record := range records {
+		record = copyRecord(record)
+		history = append(history, record)
+		m.stream = append(m.stream, StreamRecord{
+			Record:      record,
+			Offset:      uint64(len(m.stream) + 1),
+			AggregateID: aggregateID,
+		})
+	}
+	sort.Stable(history)
 	m.eventsByID[aggregateID] = history
 
-	return nil
+	if len(records) > 0 {
+		m.broadcast()
+	}
+}
+
+// broadcast wakes any callers waiting on Notify; callers must hold the lock
+func (m *MemoryStore) broadcast() {
+	if m.notify != nil {
+		close(m.notify)
+		m.notify = nil
+	}
+}
+
+// Notify implements StreamNotifier
+func (m *MemoryStore) Notify() <-chan struct{} {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if m.notify == nil {
+		m.notify = make(chan struct{})
+	}
+	return m.notify
+}
+
+// Subscribe delivers records from the stream, beginning at fromOffset, as they are saved.
+// See the package level Subscribe for details
+func (m *MemoryStore) Subscribe(ctx context.Context, fromOffset uint64) (<-chan StreamRecord, <-chan error) {
+	return Subscribe(ctx, m, fromOffset)
 }
 
-func (m *memoryStore) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (History, error) {
+// Load implements Store
+func (m *MemoryStore) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (History, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
 	all, ok := m.eventsByID[aggregateID]
 	if !ok {
-		return nil, xerrors.Errorf("no aggregate found with id, %v: %w", aggregateID, errAggregateNotFound)
+		return nil, NewNotFoundError(aggregateID)
 	}
 
 	history := make(History, 0, len(all))
-	if len(all) > 0 {
-		for _, record := range all {
-			if v := record.Version; v >= fromVersion && (toVersion == 0 || v <= toVersion) {
-				history = append(history, record)
-			}
+	for _, record := range all {
+		if v := record.Version; v >= fromVersion && (toVersion == 0 || v <= toVersion) {
+			history = append(history, copyRecord(record))
 		}
 	}
 
-	return all, nil
+	return history, nil
+}
+
+// Read implements StreamReader.  Offsets begin at 1
+func (m *MemoryStore) Read(ctx context.Context, startingOffset uint64, recordCount int) ([]StreamRecord, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if startingOffset == 0 {
+		startingOffset = 1
+	}
+
+	var records []StreamRecord
+	for i := startingOffset - 1; i < uint64(len(m.stream)) && len(records) < recordCount; i++ {
+		record := m.stream[i]
+		record.Record = copyRecord(record.Record)
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// dumpRecord is the json representation of each line written by MemoryStore.Dump
+type dumpRecord struct {
+	Offset      uint64            `json:"offset"`
+	AggregateID string            `json:"aggregate_id"`
+	Version     int               `json:"version"`
+	Data        []byte            `json:"data"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// Dump writes the contents of the store to w as newline delimited json, one record per line,
+// in offset order.  The output can be loaded with Restore
+func (m *MemoryStore) Dump(w io.Writer) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	encoder := json.NewEncoder(w)
+	for _, record := range m.stream {
+		err := encoder.Encode(dumpRecord{
+			Offset:      record.Offset,
+			AggregateID: record.AggregateID,
+			Version:     record.Version,
+			Data:        record.Data,
+			Metadata:    record.Metadata,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to dump record at offset, %v: %v", record.Offset, err)
+		}
+	}
+
+	return nil
+}
+
+// Restore replaces the contents of the store with records previously written by Dump.  Offsets
+// must be contiguous from 1 and may be omitted when writing fixtures by hand.  The store is left
+// unchanged if r cannot be read in its entirety
+func (m *MemoryStore) Restore(r io.Reader) error {
+	var (
+		eventsByID = map[string]History{}
+		stream     []StreamRecord
+		decoder    = json.NewDecoder(r)
+	)
+
+	for {
+		var record dumpRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("unable to restore record: %v", err)
+		}
+
+		offset := uint64(len(stream) + 1)
+		if record.Offset == 0 {
+			record.Offset = offset
+		} else if record.Offset != offset {
+			return fmt.Errorf("unable to restore record at offset, %v: expected offset %v", record.Offset, offset)
+		}
+		if record.AggregateID == "" {
+			return fmt.Errorf("unable to restore record at offset, %v: aggregate_id not set", record.Offset)
+		}
+
+		stream = append(stream, StreamRecord{
+			Record:      Record{Version: record.Version, Data: record.Data, Metadata: record.Metadata},
+			Offset:      record.Offset,
+			AggregateID: record.AggregateID,
+		})
+		eventsByID[record.AggregateID] = append(eventsByID[record.AggregateID], Record{Version: record.Version, Data: record.Data, Metadata: record.Metadata})
+	}
+
+	for _, history := range eventsByID {
+		sort.Stable(history)
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.eventsByID = eventsByID
+	m.stream = stream
+	m.broadcast()
+
+	return nil
+}
+
+func copyRecord(record Record) Record {
+	if record.Data != nil {
+		data := make([]byte, len(record.Data))
+		copy(data, record.Data)
+		record.Data = data
+	}
+	if record.Metadata != nil {
+		metadata := make(map[string]string, len(record.Metadata))
+		for k, v := range record.Metadata {
+			metadata[k] = v
+		}
+		record.Metadata = metadata
+	}
+	return record
 }
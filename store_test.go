@@ -1,10 +1,15 @@
 package eventsource_test
 
 import (
+	"bytes"
+	"context"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/storetest"
 )
 
 func TestHistory_Swap(t *testing.T) {
@@ -25,3 +30,96 @@ func TestHistory_Swap(t *testing.T) {
 		t.Errorf("got %v; want %v", got, want)
 	}
 }
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) eventsource.Store {
+		return eventsource.NewMemoryStore()
+	})
+}
+
+func TestMemoryStore_DumpRestore(t *testing.T) {
+	ctx := context.Background()
+
+	store := eventsource.NewMemoryStore()
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 1, Data: []byte("a1")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Save(ctx, "b", eventsource.Record{Version: 1, Data: []byte("b1")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 2, Data: []byte("a2")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := store.Dump(buf); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	restored := eventsource.NewMemoryStore()
+	if err := restored.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want, err := store.Read(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	got, err := restored.Read(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	history, err := restored.Load(ctx, "a", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(history), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// New records continue from the restored offsets
+	if err := restored.SaveVersion(ctx, "b", 1, eventsource.Record{Version: 2, Data: []byte("b2")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	records, err := restored.Read(ctx, 4, 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(records), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := records[0].Offset, uint64(4); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestMemoryStore_RestoreInvalid(t *testing.T) {
+	testCases := map[string]string{
+		"malformed":       `{"offset":1,`,
+		"missing id":      `{"offset":1,"version":1}`,
+		"offset mismatch": `{"offset":1,"aggregate_id":"a","version":1}` + "\n" + `{"offset":3,"aggregate_id":"a","version":2}`,
+	}
+
+	for label, input := range testCases {
+		t.Run(label, func(t *testing.T) {
+			ctx := context.Background()
+			store := eventsource.NewMemoryStore()
+			if err := store.Save(ctx, "abc", eventsource.Record{Version: 1}); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+
+			if err := store.Restore(strings.NewReader(input)); err == nil {
+				t.Fatalf("got nil; want not nil")
+			}
+
+			// The existing contents should be left untouched
+			if _, err := store.Load(ctx, "abc", 0, 0); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+		})
+	}
+}
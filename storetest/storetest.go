@@ -0,0 +1,560 @@
+// Package storetest provides a conformance test suite for implementations of eventsource.Store.
+//
+// Run exercises the eventsource.Store contract along with eventsource.VersionedStore,
+// eventsource.AggregateSaver and eventsource.StreamReader for stores that implement them:
+//
+//	func TestStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) eventsource.Store {
+//			return NewStore()
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// Factory returns a new, empty Store.  Factory is called once per test; use t.Cleanup to
+// release any resources held by the Store
+type Factory func(t *testing.T) eventsource.Store
+
+// concurrency is the number of goroutines used by the concurrent tests
+const concurrency = 8
+
+// Run runs the conformance suite against the stores returned by newStore
+func Run(t *testing.T, newStore Factory) {
+	t.Run("Store", func(t *testing.T) {
+		t.Run("load returns records in version order", func(t *testing.T) { testHistoryOrder(t, newStore(t)) })
+		t.Run("load filters by version", func(t *testing.T) { testVersionRange(t, newStore(t)) })
+		t.Run("load returns not found", func(t *testing.T) { testNotFound(t, newStore(t)) })
+		t.Run("aggregates are isolated", func(t *testing.T) { testIsolation(t, newStore(t)) })
+		t.Run("concurrent saves", func(t *testing.T) { testConcurrentSaves(t, newStore(t)) })
+		t.Run("metadata is preserved", func(t *testing.T) { testMetadata(t, newStore(t)) })
+	})
+
+	t.Run("VersionedStore", func(t *testing.T) {
+		store, ok := newStore(t).(eventsource.VersionedStore)
+		if !ok {
+			t.Skip("store does not implement eventsource.VersionedStore")
+		}
+		testSaveVersion(t, store)
+	})
+
+	t.Run("VersionedStore save with a gap", func(t *testing.T) {
+		store, ok := newStore(t).(eventsource.VersionedStore)
+		if !ok {
+			t.Skip("store does not implement eventsource.VersionedStore")
+		}
+		testSaveVersionGap(t, store)
+	})
+
+	t.Run("VersionedStore concurrent saves", func(t *testing.T) {
+		store, ok := newStore(t).(eventsource.VersionedStore)
+		if !ok {
+			t.Skip("store does not implement eventsource.VersionedStore")
+		}
+		testConcurrentSaveVersion(t, store)
+	})
+
+	t.Run("AggregateSaver", func(t *testing.T) {
+		store := newStore(t)
+		if _, ok := store.(eventsource.AggregateSaver); !ok {
+			t.Skip("store does not implement eventsource.AggregateSaver")
+		}
+		testSaveAggregate(t, store)
+	})
+
+	t.Run("StreamReader", func(t *testing.T) {
+		store := newStore(t)
+		if _, ok := store.(eventsource.StreamReader); !ok {
+			t.Skip("store does not implement eventsource.StreamReader")
+		}
+		testStreamReader(t, store)
+	})
+
+	t.Run("StreamReader concurrent writers", func(t *testing.T) {
+		store := newStore(t)
+		if _, ok := store.(eventsource.StreamReader); !ok {
+			t.Skip("store does not implement eventsource.StreamReader")
+		}
+		testStreamConcurrentWriters(t, store)
+	})
+}
+
+// newRecord returns a record whose data identifies the aggregate and version
+func newRecord(aggregateID string, version int) eventsource.Record {
+	return eventsource.Record{
+		Version: version,
+		Data:    []byte(fmt.Sprintf("%v:%v", aggregateID, version)),
+	}
+}
+
+func newRecords(aggregateID string, versions ...int) []eventsource.Record {
+	records := make([]eventsource.Record, 0, len(versions))
+	for _, version := range versions {
+		records = append(records, newRecord(aggregateID, version))
+	}
+	return records
+}
+
+func versions(history eventsource.History) []int {
+	v := make([]int, 0, len(history))
+	for _, record := range history {
+		v = append(v, record.Version)
+	}
+	return v
+}
+
+func mustSave(t *testing.T, store eventsource.Store, aggregateID string, versions ...int) {
+	t.Helper()
+	if err := store.Save(context.Background(), aggregateID, newRecords(aggregateID, versions...)...); err != nil {
+		t.Fatalf("Save(%v, %v): got %v; want nil", aggregateID, versions, err)
+	}
+}
+
+func mustLoad(t *testing.T, store eventsource.Store, aggregateID string, fromVersion, toVersion int) eventsource.History {
+	t.Helper()
+	history, err := store.Load(context.Background(), aggregateID, fromVersion, toVersion)
+	if err != nil {
+		t.Fatalf("Load(%v, %v, %v): got %v; want nil", aggregateID, fromVersion, toVersion, err)
+	}
+	return history
+}
+
+func testHistoryOrder(t *testing.T, store eventsource.Store) {
+	mustSave(t, store, "abc", 3)
+	mustSave(t, store, "abc", 1, 2)
+	mustSave(t, store, "abc", 4, 5)
+
+	history := mustLoad(t, store, "abc", 0, 0)
+	if got, want := versions(history), []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for _, record := range history {
+		if got, want := record, newRecord("abc", record.Version); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
+
+func testVersionRange(t *testing.T, store eventsource.Store) {
+	mustSave(t, store, "abc", 1, 2, 3, 4, 5)
+
+	testCases := map[string]struct {
+		From, To int
+		Want     []int
+	}{
+		"all":          {From: 0, To: 0, Want: []int{1, 2, 3, 4, 5}},
+		"from":         {From: 3, To: 0, Want: []int{3, 4, 5}},
+		"to":           {From: 0, To: 2, Want: []int{1, 2}},
+		"between":      {From: 2, To: 4, Want: []int{2, 3, 4}},
+		"single":       {From: 3, To: 3, Want: []int{3}},
+		"past the end": {From: 6, To: 0, Want: []int{}},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			history := mustLoad(t, store, "abc", tc.From, tc.To)
+			if got, want := versions(history), tc.Want; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func testNotFound(t *testing.T, store eventsource.Store) {
+	_, err := store.Load(context.Background(), "does-not-exist", 0, 0)
+	if err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	if !eventsource.IsNotFoundError(err) {
+		t.Fatalf("got %v; want error detectable with IsNotFoundError", err)
+	}
+}
+
+func testIsolation(t *testing.T, store eventsource.Store) {
+	mustSave(t, store, "a", 1, 2)
+	mustSave(t, store, "b", 1)
+	mustSave(t, store, "a", 3)
+
+	if got, want := mustLoad(t, store, "a", 0, 0), eventsource.History(newRecords("a", 1, 2, 3)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := mustLoad(t, store, "b", 0, 0), eventsource.History(newRecords("b", 1)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func testMetadata(t *testing.T, store eventsource.Store) {
+	ctx := context.Background()
+	records := newRecords("abc", 1, 2)
+	records[0].Metadata = map[string]string{
+		eventsource.MetadataEventID:       "1",
+		eventsource.MetadataCorrelationID: "request",
+		"unicode":                         "héllo, wörld",
+	}
+	if err := store.Save(ctx, "abc", records...); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// records saved without metadata should load without metadata
+	if got, want := mustLoad(t, store, "abc", 0, 0), eventsource.History(records); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	reader, ok := store.(eventsource.StreamReader)
+	if !ok {
+		return
+	}
+	stream, err := reader.Read(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	assertStream(t, stream, []eventsource.StreamRecord{
+		{AggregateID: "abc", Record: records[0]},
+		{AggregateID: "abc", Record: records[1]},
+	})
+}
+
+func testConcurrentSaves(t *testing.T, store eventsource.Store) {
+	const n = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency*n)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(aggregateID string) {
+			defer wg.Done()
+			for version := 1; version <= n; version++ {
+				if err := store.Save(context.Background(), aggregateID, newRecord(aggregateID, version)); err != nil {
+					errs <- err
+				}
+				if _, err := store.Load(context.Background(), aggregateID, 0, 0); err != nil {
+					errs <- err
+				}
+			}
+		}(fmt.Sprintf("aggregate-%v", i))
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	for i := 0; i < concurrency; i++ {
+		aggregateID := fmt.Sprintf("aggregate-%v", i)
+		if got, want := len(mustLoad(t, store, aggregateID, 0, 0)), n; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
+
+func testSaveVersion(t *testing.T, store eventsource.VersionedStore) {
+	ctx := context.Background()
+
+	if err := store.SaveVersion(ctx, "abc", 0, newRecords("abc", 1, 2)...); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.SaveVersion(ctx, "abc", 2, newRecords("abc", 3)...); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	err := store.SaveVersion(ctx, "abc", 2, newRecords("abc", 3)...)
+	if !eventsource.IsConflictError(err) {
+		t.Fatalf("got %v; want error detectable with IsConflictError", err)
+	}
+
+	err = store.SaveVersion(ctx, "abc", 0, newRecords("abc", 1)...)
+	if !eventsource.IsConflictError(err) {
+		t.Fatalf("got %v; want error detectable with IsConflictError", err)
+	}
+
+	if s, ok := store.(eventsource.Store); ok {
+		if got, want := versions(mustLoad(t, s, "abc", 0, 0)), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
+
+func testSaveVersionGap(t *testing.T, store eventsource.VersionedStore) {
+	ctx := context.Background()
+
+	if err := store.SaveVersion(ctx, "abc", 0, newRecords("abc", 1, 2)...); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// expectedVersion is beyond the latest version saved
+	err := store.SaveVersion(ctx, "abc", 3, newRecords("abc", 4)...)
+	if !eventsource.IsConflictError(err) {
+		t.Fatalf("got %v; want error detectable with IsConflictError", err)
+	}
+
+	// expectedVersion is several versions beyond the latest version saved
+	err = store.SaveVersion(ctx, "abc", 5, newRecords("abc", 6)...)
+	if !eventsource.IsConflictError(err) {
+		t.Fatalf("got %v; want error detectable with IsConflictError", err)
+	}
+
+	// the aggregate has not been saved
+	err = store.SaveVersion(ctx, "def", 1, newRecords("def", 2)...)
+	if !eventsource.IsConflictError(err) {
+		t.Fatalf("got %v; want error detectable with IsConflictError", err)
+	}
+
+	if s, ok := store.(eventsource.Store); ok {
+		if got, want := versions(mustLoad(t, s, "abc", 0, 0)), []int{1, 2}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if _, err := s.Load(ctx, "def", 0, 0); !eventsource.IsNotFoundError(err) {
+			t.Fatalf("got %v; want error detectable with IsNotFoundError", err)
+		}
+	}
+}
+
+func testConcurrentSaveVersion(t *testing.T, store eventsource.VersionedStore) {
+	var (
+		wg        sync.WaitGroup
+		mux       sync.Mutex
+		saved     int
+		conflicts int
+		errs      []error
+	)
+
+	start := make(chan struct{})
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			err := store.SaveVersion(context.Background(), "abc", 0, newRecord("abc", 1))
+
+			mux.Lock()
+			defer mux.Unlock()
+			switch {
+			case err == nil:
+				saved++
+			case eventsource.IsConflictError(err):
+				conflicts++
+			default:
+				errs = append(errs, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range errs {
+		t.Fatalf("got %v; want nil or conflict", err)
+	}
+	if got, want := saved, 1; got != want {
+		t.Fatalf("got %v saves; want %v", got, want)
+	}
+	if got, want := conflicts, concurrency-1; got != want {
+		t.Fatalf("got %v conflicts; want %v", got, want)
+	}
+}
+
+func testSaveAggregate(t *testing.T, store eventsource.Store) {
+	ctx := context.Background()
+	saver := store.(eventsource.AggregateSaver)
+
+	input := eventsource.SaveAggregateInput{
+		AggregateID: "abc",
+		Records:     newRecords("abc", 1, 2),
+	}
+	if err := saver.SaveAggregate(ctx, input); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := versions(mustLoad(t, store, "abc", 0, 0)), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	input = eventsource.SaveAggregateInput{
+		AggregateID:     "abc",
+		Records:         newRecords("abc", 2),
+		ExpectedVersion: 1,
+	}
+	err := saver.SaveAggregate(ctx, input)
+	if !eventsource.IsConflictError(err) {
+		t.Fatalf("got %v; want error detectable with IsConflictError", err)
+	}
+}
+
+func testStreamReader(t *testing.T, store eventsource.Store) {
+	ctx := context.Background()
+	reader := store.(eventsource.StreamReader)
+
+	records, err := reader.Read(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(records), 0; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	mustSave(t, store, "a", 1, 2)
+	mustSave(t, store, "b", 1)
+	mustSave(t, store, "a", 3)
+	mustSave(t, store, "c", 1, 2)
+
+	want := []eventsource.StreamRecord{
+		{AggregateID: "a", Record: newRecord("a", 1)},
+		{AggregateID: "a", Record: newRecord("a", 2)},
+		{AggregateID: "b", Record: newRecord("b", 1)},
+		{AggregateID: "a", Record: newRecord("a", 3)},
+		{AggregateID: "c", Record: newRecord("c", 1)},
+		{AggregateID: "c", Record: newRecord("c", 2)},
+	}
+
+	t.Run("read all", func(t *testing.T) {
+		records, err := reader.Read(ctx, 0, 100)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		assertStream(t, records, want)
+	})
+
+	t.Run("read in pages", func(t *testing.T) {
+		var all []eventsource.StreamRecord
+		var offset uint64
+		for {
+			records, err := reader.Read(ctx, offset, 4)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if len(records) > 4 {
+				t.Fatalf("got %v records; want at most 4", len(records))
+			}
+			if len(records) == 0 {
+				break
+			}
+			all = append(all, records...)
+			offset = records[len(records)-1].Offset + 1
+		}
+		assertStream(t, all, want)
+	})
+
+	t.Run("read from offset", func(t *testing.T) {
+		all, err := reader.Read(ctx, 0, 100)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		records, err := reader.Read(ctx, all[2].Offset, 100)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		assertStream(t, records, want[2:])
+	})
+}
+
+// testStreamConcurrentWriters reads the stream while several writers save to it.  A reader that
+// advances its offset past each record it receives must see every record exactly once, which
+// fails for stores where a record may commit after a record with a higher offset has been read
+func testStreamConcurrentWriters(t *testing.T, store eventsource.Store) {
+	const n = 10
+
+	ctx := context.Background()
+	reader := store.(eventsource.StreamReader)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency*n)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(aggregateID string) {
+			defer wg.Done()
+			for version := 1; version <= n; version++ {
+				if err := store.Save(ctx, aggregateID, newRecord(aggregateID, version)); err != nil {
+					errs <- err
+				}
+			}
+		}(fmt.Sprintf("aggregate-%v", i))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var (
+		offset   uint64
+		previous uint64
+		seen     = map[string]int{}
+		count    int
+		deadline = time.Now().Add(time.Minute)
+	)
+	for count < concurrency*n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %v records; want %v", count, concurrency*n)
+		}
+
+		select {
+		case err := <-errs:
+			t.Fatalf("got %v; want nil", err)
+		default:
+		}
+
+		records, err := reader.Read(ctx, offset, 25)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		for _, record := range records {
+			if record.Offset <= previous {
+				t.Fatalf("got offset %v after %v; want increasing offsets", record.Offset, previous)
+			}
+			previous = record.Offset
+
+			if got, want := record.Version, seen[record.AggregateID]+1; got != want {
+				t.Fatalf("got version %v of %v; want %v", got, record.AggregateID, want)
+			}
+			seen[record.AggregateID] = record.Version
+			count++
+			offset = record.Offset + 1
+		}
+
+		if len(records) == 0 {
+			select {
+			case <-done:
+				// writers have finished; a further empty read means records were skipped
+				if records, err := reader.Read(ctx, offset, 1); err == nil && len(records) == 0 {
+					t.Fatalf("got %v records; want %v", count, concurrency*n)
+				}
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+	<-done
+}
+
+// assertStream verifies the records match want, ignoring offsets, and that offsets strictly
+// increase
+func assertStream(t *testing.T, records, want []eventsource.StreamRecord) {
+	t.Helper()
+
+	if got, want := len(records), len(want); got != want {
+		t.Fatalf("got %v records; want %v", got, want)
+	}
+
+	var previous uint64
+	for i, record := range records {
+		if record.Offset <= previous {
+			t.Fatalf("got offset %v after %v; want increasing offsets", record.Offset, previous)
+		}
+		previous = record.Offset
+
+		record.Offset = 0
+		if got, want := record, want[i]; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
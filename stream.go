@@ -1,6 +1,11 @@
 package eventsource
 
-import "context"
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/xerrors"
+)
 
 // StreamRecord provides a serialized version of the event stream
 type StreamRecord struct {
@@ -25,3 +30,79 @@ type StreamReaderFunc func(ctx context.Context, startingOffset uint64, recordCou
 func (fn StreamReaderFunc) Read(ctx context.Context, startingOffset uint64, recordCount int) ([]StreamRecord, error) {
 	return fn(ctx, startingOffset, recordCount)
 }
+
+// StreamNotifier is an optional interface for stores that can signal when new records have
+// been committed to the stream
+type StreamNotifier interface {
+	// Notify returns a channel that will be closed the next time records are committed.
+	// Callers should obtain the channel before reading the stream so that records committed
+	// during the read are not missed
+	Notify() <-chan struct{}
+}
+
+// subscribeBatchSize is the number of records read from the stream at a time by Subscribe
+const subscribeBatchSize = 100
+
+// Subscribe delivers the records of the stream, beginning at fromOffset, over the returned
+// channel.  Historical records are read first using the StreamReader after which Subscribe
+// waits for the StreamNotifier to signal new records, so each record is delivered once and in
+// order.  The reader must implement StreamNotifier.
+//
+// When ctx is cancelled both channels are closed.  If the stream cannot be read, the error
+// is sent on the error channel before both channels are closed
+func Subscribe(ctx context.Context, reader StreamReader, fromOffset uint64) (<-chan StreamRecord, <-chan error) {
+	records := make(chan StreamRecord)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(records)
+
+		notifier, ok := reader.(StreamNotifier)
+		if !ok {
+			errs <- fmt.Errorf("unable to subscribe; %T does not implement StreamNotifier", reader)
+			return
+		}
+
+		offset := fromOffset
+		if offset == 0 {
+			offset = 1
+		}
+
+		for {
+			notify := notifier.Notify()
+
+			batch, err := reader.Read(ctx, offset, subscribeBatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					errs <- xerrors.Errorf("unable to read stream from offset, %v: %w", offset, err)
+				}
+				return
+			}
+
+			for _, record := range batch {
+				if record.Offset < offset {
+					continue
+				}
+				select {
+				case records <- record:
+				case <-ctx.Done():
+					return
+				}
+				offset = record.Offset + 1
+			}
+
+			if len(batch) == subscribeBatchSize {
+				continue
+			}
+
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return records, errs
+}
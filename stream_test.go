@@ -0,0 +1,78 @@
+package eventsource_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func receive(t *testing.T, records <-chan eventsource.StreamRecord) eventsource.StreamRecord {
+	t.Helper()
+
+	select {
+	case record, ok := <-records:
+		if !ok {
+			t.Fatalf("got closed channel; want record")
+		}
+		return record
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for record")
+	}
+	return eventsource.StreamRecord{}
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := eventsource.NewMemoryStore()
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 1}, eventsource.Record{Version: 2}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	records, errs := store.Subscribe(ctx, 2)
+
+	// catch up with the historical records
+	if got, want := receive(t, records).Offset, uint64(2); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// then switch to live records
+	go func() {
+		for version := 3; version <= 5; version++ {
+			store.Save(ctx, "a", eventsource.Record{Version: version})
+		}
+	}()
+	for want := uint64(3); want <= 5; want++ {
+		record := receive(t, records)
+		if got := record.Offset; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := record.Version, int(want); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+
+	cancel()
+	for range records {
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+}
+
+func TestSubscribe_NotNotifier(t *testing.T) {
+	reader := eventsource.StreamReaderFunc(func(ctx context.Context, startingOffset uint64, recordCount int) ([]eventsource.StreamRecord, error) {
+		return nil, nil
+	})
+
+	records, errs := eventsource.Subscribe(context.Background(), reader, 0)
+	if err := <-errs; err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	if _, ok := <-records; ok {
+		t.Fatalf("got open channel; want closed")
+	}
+}
@@ -0,0 +1,48 @@
+package subscription
+
+import (
+	"context"
+	"sync"
+)
+
+// CheckpointStore persists the offset of the last record processed by a subscription so that
+// processing can resume where it left off after a restart
+type CheckpointStore interface {
+	// LoadCheckpoint returns the last offset saved for the named subscription or 0 if no
+	// checkpoint has been saved
+	LoadCheckpoint(ctx context.Context, name string) (uint64, error)
+
+	// SaveCheckpoint records offset as the last offset processed by the named subscription
+	SaveCheckpoint(ctx context.Context, name string, offset uint64) error
+}
+
+// MemoryCheckpointStore provides an in-memory implementation of CheckpointStore suitable for
+// testing or for subscriptions that always replay from the beginning of the stream
+type MemoryCheckpointStore struct {
+	mux         sync.Mutex
+	checkpoints map[string]uint64
+}
+
+// NewMemoryCheckpointStore returns an empty MemoryCheckpointStore
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		checkpoints: map[string]uint64{},
+	}
+}
+
+// LoadCheckpoint implements CheckpointStore
+func (m *MemoryCheckpointStore) LoadCheckpoint(ctx context.Context, name string) (uint64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return m.checkpoints[name], nil
+}
+
+// SaveCheckpoint implements CheckpointStore
+func (m *MemoryCheckpointStore) SaveCheckpoint(ctx context.Context, name string, offset uint64) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.checkpoints[name] = offset
+	return nil
+}
@@ -0,0 +1,204 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// DeadLetter describes a record that could not be handled within the retry limit
+type DeadLetter struct {
+	// Subscription contains the name of the subscription that parked the record
+	Subscription string
+
+	// Record contains the record that could not be handled
+	Record eventsource.StreamRecord
+
+	// Error contains the message of the last error returned for the record
+	Error string
+
+	// Attempts contains the number of times the record was handled unsuccessfully
+	Attempts int
+
+	// At indicates when the record was last attempted
+	At time.Time
+}
+
+// DeadLetterStore persists records that a subscription has given up on
+type DeadLetterStore interface {
+	// SaveDeadLetter saves the dead letter, replacing any existing dead letter for the same
+	// subscription and offset
+	SaveDeadLetter(ctx context.Context, deadLetter DeadLetter) error
+
+	// ListDeadLetters returns the dead letters for the named subscription in offset order
+	ListDeadLetters(ctx context.Context, subscription string) ([]DeadLetter, error)
+
+	// DeleteDeadLetter removes the dead letter for the named subscription and offset
+	DeleteDeadLetter(ctx context.Context, subscription string, offset uint64) error
+}
+
+// WithDeadLetters parks records that fail maxAttempts times in store, allowing the
+// subscription to continue with the next record.  Each poll makes one attempt at a failing
+// record so, with Run, the attempts are separated by the backoff.  Parked records may be
+// inspected, replayed or discarded with DeadLetters, Replay and Discard
+func WithDeadLetters(store DeadLetterStore, maxAttempts int) Option {
+	return func(s *Subscription) {
+		if maxAttempts <= 0 {
+			maxAttempts = 1
+		}
+		s.deadLetters = store
+		s.maxAttempts = maxAttempts
+	}
+}
+
+// process handles the record and, once the record has failed maxAttempts times, parks it in
+// the DeadLetterStore
+func (s *Subscription) process(ctx context.Context, record eventsource.StreamRecord) error {
+	err := s.handle(ctx, record)
+	if s.deadLetters == nil {
+		return err
+	}
+
+	s.attemptsMux.Lock()
+	if err == nil {
+		delete(s.attempts, record.Offset)
+		s.attemptsMux.Unlock()
+		return nil
+	}
+	if s.attempts == nil {
+		s.attempts = map[uint64]int{}
+	}
+	s.attempts[record.Offset]++
+	attempts := s.attempts[record.Offset]
+	s.attemptsMux.Unlock()
+
+	if ctx.Err() != nil || attempts < s.maxAttempts {
+		return err
+	}
+
+	deadLetter := DeadLetter{
+		Subscription: s.name,
+		Record:       record,
+		Error:        err.Error(),
+		Attempts:     attempts,
+		At:           time.Now(),
+	}
+	if err := s.deadLetters.SaveDeadLetter(ctx, deadLetter); err != nil {
+		return fmt.Errorf("unable to save dead letter for record at offset, %v: %v", record.Offset, err)
+	}
+	s.logf("%v: parked record at offset %v after %v attempt(s): %v", s.name, record.Offset, attempts, deadLetter.Error)
+
+	s.attemptsMux.Lock()
+	delete(s.attempts, record.Offset)
+	s.attemptsMux.Unlock()
+
+	return nil
+}
+
+// DeadLetters returns the records parked by this subscription
+func (s *Subscription) DeadLetters(ctx context.Context) ([]DeadLetter, error) {
+	if s.deadLetters == nil {
+		return nil, errDeadLettersNotEnabled
+	}
+	return s.deadLetters.ListDeadLetters(ctx, s.name)
+}
+
+// Replay passes the parked record at offset to the Handler again.  If the record is handled
+// successfully it is removed from the DeadLetterStore, otherwise the dead letter is updated
+// with the error and the error is returned.  Replay may be called while Run is active, so the
+// Handler will be called concurrently
+func (s *Subscription) Replay(ctx context.Context, offset uint64) error {
+	if s.deadLetters == nil {
+		return errDeadLettersNotEnabled
+	}
+
+	deadLetters, err := s.deadLetters.ListDeadLetters(ctx, s.name)
+	if err != nil {
+		return err
+	}
+
+	for _, deadLetter := range deadLetters {
+		if deadLetter.Record.Offset != offset {
+			continue
+		}
+
+		if err := s.handle(ctx, deadLetter.Record); err != nil {
+			deadLetter.Error = err.Error()
+			deadLetter.Attempts++
+			deadLetter.At = time.Now()
+			if saveErr := s.deadLetters.SaveDeadLetter(ctx, deadLetter); saveErr != nil {
+				return fmt.Errorf("unable to save dead letter for record at offset, %v: %v", offset, saveErr)
+			}
+			return err
+		}
+
+		return s.deadLetters.DeleteDeadLetter(ctx, s.name, offset)
+	}
+
+	return fmt.Errorf("no dead letter found for subscription, %v, at offset %v", s.name, offset)
+}
+
+// Discard removes the parked record at offset without handling it
+func (s *Subscription) Discard(ctx context.Context, offset uint64) error {
+	if s.deadLetters == nil {
+		return errDeadLettersNotEnabled
+	}
+	return s.deadLetters.DeleteDeadLetter(ctx, s.name, offset)
+}
+
+// MemoryDeadLetterStore provides an in-memory implementation of DeadLetterStore
+type MemoryDeadLetterStore struct {
+	mux         sync.Mutex
+	deadLetters map[string]map[uint64]DeadLetter
+}
+
+// NewMemoryDeadLetterStore returns an empty MemoryDeadLetterStore
+func NewMemoryDeadLetterStore() *MemoryDeadLetterStore {
+	return &MemoryDeadLetterStore{
+		deadLetters: map[string]map[uint64]DeadLetter{},
+	}
+}
+
+// SaveDeadLetter implements DeadLetterStore
+func (m *MemoryDeadLetterStore) SaveDeadLetter(ctx context.Context, deadLetter DeadLetter) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	bySubscription, ok := m.deadLetters[deadLetter.Subscription]
+	if !ok {
+		bySubscription = map[uint64]DeadLetter{}
+		m.deadLetters[deadLetter.Subscription] = bySubscription
+	}
+	bySubscription[deadLetter.Record.Offset] = deadLetter
+
+	return nil
+}
+
+// ListDeadLetters implements DeadLetterStore
+func (m *MemoryDeadLetterStore) ListDeadLetters(ctx context.Context, subscription string) ([]DeadLetter, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	deadLetters := make([]DeadLetter, 0, len(m.deadLetters[subscription]))
+	for _, deadLetter := range m.deadLetters[subscription] {
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].Record.Offset < deadLetters[j].Record.Offset
+	})
+
+	return deadLetters, nil
+}
+
+// DeleteDeadLetter implements DeadLetterStore
+func (m *MemoryDeadLetterStore) DeleteDeadLetter(ctx context.Context, subscription string, offset uint64) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	delete(m.deadLetters[subscription], offset)
+	return nil
+}
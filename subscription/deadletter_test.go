@@ -0,0 +1,133 @@
+package subscription_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/subscription"
+)
+
+// poison fails to handle the record at the specified offset until fixed
+type poison struct {
+	mux     sync.Mutex
+	offset  uint64
+	fixed   bool
+	handled []uint64
+}
+
+func (p *poison) Handle(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if record.Offset == p.offset && !p.fixed {
+		return errors.New("poison")
+	}
+	p.handled = append(p.handled, record.Offset)
+	return nil
+}
+
+func TestWithDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	serializer := eventsource.NewJSONSerializer(ItemAdded{})
+	store := newStore(t, serializer, 3)
+	deadLetters := subscription.NewMemoryDeadLetterStore()
+	handler := &poison{offset: 2}
+
+	sub := subscription.New("test", store, handler, subscription.WithDeadLetters(deadLetters, 3))
+
+	// the first attempts fail and the subscription does not advance past the record
+	for attempt := 1; attempt < 3; attempt++ {
+		if _, err := sub.Poll(ctx); err == nil {
+			t.Fatalf("got nil; want not nil")
+		}
+		if got, want := sub.Offset(), uint64(1); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+
+	// the final attempt parks the record and processing continues
+	if _, err := sub.Poll(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := sub.Offset(), uint64(3); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	parked, err := sub.DeadLetters(ctx)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(parked), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := parked[0].Record.Offset, uint64(2); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := parked[0].Attempts, 3; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if parked[0].Error == "" {
+		t.Fatalf("got empty error; want error message")
+	}
+
+	// replay fails while the handler is still broken
+	if err := sub.Replay(ctx, 2); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	parked, _ = sub.DeadLetters(ctx)
+	if got, want := parked[0].Attempts, 4; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// and succeeds once fixed
+	handler.fixed = true
+	if err := sub.Replay(ctx, 2); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	parked, _ = sub.DeadLetters(ctx)
+	if got, want := len(parked), 0; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := handler.handled, []uint64{1, 3, 2}; len(got) != len(want) || got[2] != want[2] {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestWithDeadLetters_Discard(t *testing.T) {
+	ctx := context.Background()
+	serializer := eventsource.NewJSONSerializer(ItemAdded{})
+	store := newStore(t, serializer, 2)
+	handler := &poison{offset: 1}
+
+	sub := subscription.New("test", store, handler,
+		subscription.WithDeadLetters(subscription.NewMemoryDeadLetterStore(), 1),
+	)
+	if _, err := sub.Poll(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if err := sub.Discard(ctx, 1); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	parked, err := sub.DeadLetters(ctx)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(parked), 0; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if err := sub.Replay(ctx, 1); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
+
+func TestDeadLetters_NotEnabled(t *testing.T) {
+	sub := subscription.New("test", eventsource.NewMemoryStore(), &poison{})
+	if _, err := sub.DeadLetters(context.Background()); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
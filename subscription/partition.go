@@ -0,0 +1,88 @@
+package subscription
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// WithPartitions handles records concurrently across n partitions.  Records are assigned to a
+// partition by hashing their AggregateID so the records of each aggregate are handled in
+// order, one at a time, while records of different aggregates may be handled in parallel.
+//
+// The checkpoint only advances to the highest offset for which it and every lower offset have
+// been handled.  Records beyond the checkpoint that were handled before another partition
+// failed are remembered and not handed to the Handler again by this Subscription, however
+// they may be redelivered after a restart.
+//
+// The Handler must be safe for concurrent use when n is greater than 1
+func WithPartitions(n int) Option {
+	return func(s *Subscription) {
+		if n > 0 {
+			s.partitions = n
+		}
+	}
+}
+
+// partition returns the partition, in the range [0, n), for the aggregate
+func partition(aggregateID string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(aggregateID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// handlePartitions handles the records concurrently across partitions.  Each partition stops
+// at its first error; the error returned is that of the earliest record in the stream that
+// failed
+func (s *Subscription) handlePartitions(ctx context.Context, records []eventsource.StreamRecord) ([]bool, error) {
+	if s.handled == nil {
+		s.handled = map[uint64]struct{}{}
+	}
+
+	handled := make([]bool, len(records))
+	errs := make([]error, len(records))
+
+	partitions := make([][]int, s.partitions)
+	for i, record := range records {
+		if _, ok := s.handled[record.Offset]; ok {
+			handled[i] = true
+			continue
+		}
+		p := partition(record.AggregateID, s.partitions)
+		partitions[p] = append(partitions[p], i)
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, indexes := range partitions {
+		if len(indexes) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+
+			for _, i := range indexes {
+				if err := s.process(ctx, records[i]); err != nil {
+					errs[i] = err
+					return
+				}
+				handled[i] = true
+			}
+		}(indexes)
+	}
+	wg.Wait()
+
+	var err error
+	for i, record := range records {
+		if handled[i] {
+			s.handled[record.Offset] = struct{}{}
+		} else if err == nil && errs[i] != nil {
+			err = errs[i]
+		}
+	}
+
+	return handled, err
+}
@@ -0,0 +1,145 @@
+package subscription_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/subscription"
+)
+
+// newPartitionedStore returns a store containing the specified number of versions for each
+// aggregate, interleaved within the stream
+func newPartitionedStore(t *testing.T, aggregates, versions int) *eventsource.MemoryStore {
+	store := eventsource.NewMemoryStore()
+	for version := 1; version <= versions; version++ {
+		for i := 0; i < aggregates; i++ {
+			id := fmt.Sprintf("aggregate-%v", i)
+			if err := store.Save(context.Background(), id, eventsource.Record{Version: version}); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+		}
+	}
+	return store
+}
+
+func TestWithPartitions(t *testing.T) {
+	const aggregates, versions = 16, 5
+
+	ctx := context.Background()
+	store := newPartitionedStore(t, aggregates, versions)
+	checkpoints := subscription.NewMemoryCheckpointStore()
+
+	var (
+		mux         sync.Mutex
+		active      int
+		concurrency int
+		latest      = map[string]int{}
+	)
+	handler := subscription.HandlerFunc(func(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error {
+		mux.Lock()
+		active++
+		if active > concurrency {
+			concurrency = active
+		}
+		if got, want := record.Version, latest[record.AggregateID]+1; got != want {
+			t.Errorf("got version %v for %v; want %v", got, record.AggregateID, want)
+		}
+		latest[record.AggregateID] = record.Version
+		mux.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mux.Lock()
+		active--
+		mux.Unlock()
+		return nil
+	})
+
+	sub := subscription.New("test", store, handler,
+		subscription.WithCheckpointStore(checkpoints),
+		subscription.WithPartitions(4),
+	)
+	n, err := sub.Poll(ctx)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := n, aggregates*versions; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if concurrency < 2 {
+		t.Fatalf("got concurrency %v; want at least 2", concurrency)
+	}
+
+	offset, err := checkpoints.LoadCheckpoint(ctx, "test")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := offset, uint64(aggregates*versions); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestWithPartitions_Checkpoint(t *testing.T) {
+	const aggregates, versions = 8, 3
+
+	ctx := context.Background()
+	store := newPartitionedStore(t, aggregates, versions)
+	checkpoints := subscription.NewMemoryCheckpointStore()
+
+	var (
+		mux    sync.Mutex
+		failed bool
+		counts = map[uint64]int{}
+	)
+	handler := subscription.HandlerFunc(func(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error {
+		mux.Lock()
+		defer mux.Unlock()
+
+		// fail the second record once; the records of other partitions continue to be handled
+		if record.Offset == 2 && !failed {
+			failed = true
+			return errors.New("boom")
+		}
+		counts[record.Offset]++
+		return nil
+	})
+
+	sub := subscription.New("test", store, handler,
+		subscription.WithCheckpointStore(checkpoints),
+		subscription.WithPartitions(4),
+	)
+
+	if _, err := sub.Poll(ctx); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+	offset, err := checkpoints.LoadCheckpoint(ctx, "test")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := offset, uint64(1); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if _, err := sub.Poll(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	offset, err = checkpoints.LoadCheckpoint(ctx, "test")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := offset, uint64(aggregates*versions); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// records handled before the failure should not have been handled again
+	for offset := uint64(1); offset <= aggregates*versions; offset++ {
+		if got, want := counts[offset], 1; got != want {
+			t.Fatalf("got %v for offset %v; want %v", got, offset, want)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Package subscription provides a consumer for eventsource.StreamReader.
+//
+// A Subscription polls the stream in batches, optionally decodes each record into an
+// eventsource.Event, passes it to a Handler, and saves the offset of the last record
+// processed to a CheckpointStore so that processing resumes where it left off:
+//
+//	sub := subscription.New("welcome-email", store, handler,
+//		subscription.WithSerializer(serializer),
+//		subscription.WithCheckpointStore(checkpoints),
+//	)
+//	err := sub.Run(ctx) // blocks until ctx is cancelled
+//
+// Records are delivered at least once; a handler may see a record again if the process
+// stops after the handler returns but before the checkpoint is saved.
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"golang.org/x/xerrors"
+)
+
+var errDeadLettersNotEnabled = errors.New("dead letters not enabled; see WithDeadLetters")
+
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+	defaultMinBackoff   = 100 * time.Millisecond
+	defaultMaxBackoff   = 30 * time.Second
+)
+
+// Handler processes records read from the stream
+type Handler interface {
+	// Handle processes a single record.  event contains the decoded record when the
+	// subscription was configured with a serializer and is nil otherwise; when the serializer
+	// decodes the record into more than one event, Handle is called once for each.  When Handle
+	// returns an error, the record will be retried after a backoff; see WithDeadLetters to limit
+	// the number of attempts.  ctx carries the metadata of the record, see
+	// eventsource.ContextWithCause, so events saved by commands applied with ctx record the
+	// record as their cause
+	Handle(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error
+}
+
+// HandlerFunc provides a func alternative for declaring a Handler
+type HandlerFunc func(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error
+
+// Handle implements the Handler interface
+func (fn HandlerFunc) Handle(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error {
+	return fn(ctx, record, event)
+}
+
+// Option provides functional configuration for a *Subscription
+type Option func(*Subscription)
+
+// WithBatchSize specifies the maximum number of records read from the stream at a time;
+// defaults to 100
+func WithBatchSize(n int) Option {
+	return func(s *Subscription) {
+		if n > 0 {
+			s.batchSize = n
+		}
+	}
+}
+
+// WithPollInterval specifies how long to wait before polling again once the subscription
+// has caught up with the stream; defaults to 1s
+func WithPollInterval(d time.Duration) Option {
+	return func(s *Subscription) {
+		if d > 0 {
+			s.pollInterval = d
+		}
+	}
+}
+
+// WithBackoff specifies the delay after a failed poll.  The delay starts at min and doubles
+// with each consecutive failure up to max; defaults to 100ms and 30s
+func WithBackoff(min, max time.Duration) Option {
+	return func(s *Subscription) {
+		if min > 0 {
+			s.minBackoff = min
+		}
+		if max > 0 {
+			s.maxBackoff = max
+		}
+		if s.maxBackoff < s.minBackoff {
+			s.maxBackoff = s.minBackoff
+		}
+	}
+}
+
+// WithSerializer decodes each record into an eventsource.Event before it is passed to the
+// Handler.  Without a serializer, handlers receive a nil event
+func WithSerializer(serializer eventsource.Serializer) Option {
+	return func(s *Subscription) {
+		s.serializer = serializer
+	}
+}
+
+// WithCheckpointStore specifies where offsets are saved; defaults to an in-memory store
+func WithCheckpointStore(checkpoints CheckpointStore) Option {
+	return func(s *Subscription) {
+		s.checkpoints = checkpoints
+	}
+}
+
+// WithDebug will generate additional logging useful for debugging
+func WithDebug(w io.Writer) Option {
+	return func(s *Subscription) {
+		s.writer = w
+		s.debug = true
+	}
+}
+
+// Subscription reads records from an eventsource.StreamReader and passes them to a Handler
+type Subscription struct {
+	name         string
+	reader       eventsource.StreamReader
+	handler      Handler
+	serializer   eventsource.Serializer
+	checkpoints  CheckpointStore
+	batchSize    int
+	pollInterval time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	partitions   int
+	deadLetters  DeadLetterStore
+	maxAttempts  int
+	writer       io.Writer
+	debug        bool
+
+	attemptsMux sync.Mutex
+	attempts    map[uint64]int // failed attempts by offset; only tracked with dead letters
+
+	mux     sync.Mutex
+	loaded  bool
+	offset  uint64
+	handled map[uint64]struct{} // offsets past the checkpoint already handled by a partition
+}
+
+// New returns a Subscription that passes the records read from reader to handler.  name
+// identifies the subscription's checkpoint and must be unique within the CheckpointStore
+func New(name string, reader eventsource.StreamReader, handler Handler, opts ...Option) *Subscription {
+	s := &Subscription{
+		name:         name,
+		reader:       reader,
+		handler:      handler,
+		checkpoints:  NewMemoryCheckpointStore(),
+		batchSize:    defaultBatchSize,
+		pollInterval: defaultPollInterval,
+		minBackoff:   defaultMinBackoff,
+		maxBackoff:   defaultMaxBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *Subscription) logf(format string, args ...interface{}) {
+	if !s.debug {
+		return
+	}
+
+	now := time.Now().Format(time.StampMilli)
+	io.WriteString(s.writer, now)
+	io.WriteString(s.writer, " ")
+
+	fmt.Fprintf(s.writer, format, args...)
+	if !strings.HasSuffix(format, "\n") {
+		io.WriteString(s.writer, "\n")
+	}
+}
+
+// Name returns the name of the subscription
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// Offset returns the offset of the last record processed; 0 if no records have been processed
+func (s *Subscription) Offset() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.offset
+}
+
+// Run polls the stream until ctx is cancelled.  Errors from the StreamReader, Handler or
+// CheckpointStore are retried with backoff.  When the reader implements
+// eventsource.StreamNotifier, Run polls as soon as new records are committed rather than
+// waiting for the poll interval.  Run returns nil once ctx is cancelled
+func (s *Subscription) Run(ctx context.Context) error {
+	notifier, _ := s.reader.(eventsource.StreamNotifier)
+
+	failures := 0
+	for {
+		var notify <-chan struct{}
+		if notifier != nil {
+			notify = notifier.Notify()
+		}
+
+		n, err := s.Poll(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			failures++
+			delay = s.backoff(failures)
+			notify = nil // wait out the backoff
+			s.logf("%v: poll failed, retrying in %v: %v", s.name, delay, err)
+		case n < s.batchSize:
+			failures = 0
+			delay = s.pollInterval
+		default:
+			failures = 0
+			continue // more records are likely available
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Poll reads and handles a single batch of records and returns the number of records
+// processed.  The checkpoint is saved after the batch even if a record failed, so progress
+// made prior to the failure is kept
+func (s *Subscription) Poll(ctx context.Context) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if !s.loaded {
+		offset, err := s.checkpoints.LoadCheckpoint(ctx, s.name)
+		if err != nil {
+			return 0, xerrors.Errorf("unable to load checkpoint for subscription, %v: %w", s.name, err)
+		}
+		s.offset = offset
+		s.loaded = true
+	}
+
+	records, err := s.reader.Read(ctx, s.offset+1, s.batchSize)
+	if err != nil {
+		return 0, xerrors.Errorf("unable to read stream from offset, %v: %w", s.offset+1, err)
+	}
+
+	pending := make([]eventsource.StreamRecord, 0, len(records))
+	for _, record := range records {
+		if record.Offset > s.offset {
+			pending = append(pending, record)
+		}
+	}
+
+	var handled []bool
+	if s.partitions > 1 {
+		handled, err = s.handlePartitions(ctx, pending)
+	} else {
+		handled, err = s.handleAll(ctx, pending)
+	}
+
+	// only advance the checkpoint past records that, along with every record before them,
+	// have been handled
+	start := s.offset
+	n := 0
+	for i, record := range pending {
+		if !handled[i] {
+			break
+		}
+		s.offset = record.Offset
+		delete(s.handled, record.Offset)
+		n++
+	}
+
+	if s.offset != start {
+		if err := s.checkpoints.SaveCheckpoint(ctx, s.name, s.offset); err != nil {
+			return n, xerrors.Errorf("unable to save checkpoint for subscription, %v, at offset %v: %w", s.name, s.offset, err)
+		}
+	}
+
+	return n, err
+}
+
+// handleAll handles the records in order, stopping at the first error
+func (s *Subscription) handleAll(ctx context.Context, records []eventsource.StreamRecord) ([]bool, error) {
+	handled := make([]bool, len(records))
+	for i, record := range records {
+		if err := s.process(ctx, record); err != nil {
+			return handled, err
+		}
+		handled[i] = true
+	}
+	return handled, nil
+}
+
+// handle decodes the record, if required, and passes it to the handler.  The context passed to
+// the handler carries the record as the cause so that commands applied while handling it are
+// correlated with it
+func (s *Subscription) handle(ctx context.Context, record eventsource.StreamRecord) error {
+	ctx = eventsource.ContextWithCause(ctx, record.Metadata)
+
+	events := []eventsource.Event{nil}
+	if s.serializer != nil {
+		v, err := eventsource.UnmarshalEvents(s.serializer, record.Record)
+		if err != nil {
+			return xerrors.Errorf("unable to decode record at offset, %v: %w", record.Offset, err)
+		}
+		events = v
+	}
+
+	for _, event := range events {
+		if err := s.handler.Handle(ctx, record, event); err != nil {
+			return xerrors.Errorf("unable to handle record at offset, %v: %w", record.Offset, err)
+		}
+	}
+
+	return nil
+}
+
+// backoff returns the delay following the specified number of consecutive failures
+func (s *Subscription) backoff(failures int) time.Duration {
+	d := s.minBackoff
+	for i := 1; i < failures && d < s.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.maxBackoff {
+		d = s.maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,279 @@
+package subscription_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource/subscription"
+)
+
+type ItemAdded struct {
+	eventsource.Model
+	Name string
+}
+
+// newStore returns a store containing n ItemAdded events for aggregate abc
+func newStore(t *testing.T, serializer eventsource.Serializer, n int) *eventsource.MemoryStore {
+	store := eventsource.NewMemoryStore()
+	for version := 1; version <= n; version++ {
+		record, err := serializer.MarshalEvent(ItemAdded{Model: eventsource.Model{ID: "abc", Version: version}})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if err := store.Save(context.Background(), "abc", record); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+	return store
+}
+
+// recorder captures the versions of the events handled
+type recorder struct {
+	mux      sync.Mutex
+	versions []int
+}
+
+func (r *recorder) Handle(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.versions = append(r.versions, event.EventVersion())
+	return nil
+}
+
+func (r *recorder) Len() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return len(r.versions)
+}
+
+func TestSubscription_Poll(t *testing.T) {
+	ctx := context.Background()
+	serializer := eventsource.NewJSONSerializer(ItemAdded{})
+	store := newStore(t, serializer, 5)
+	checkpoints := subscription.NewMemoryCheckpointStore()
+	handler := &recorder{}
+
+	sub := subscription.New("test", store, handler,
+		subscription.WithSerializer(serializer),
+		subscription.WithCheckpointStore(checkpoints),
+		subscription.WithBatchSize(2),
+	)
+
+	for _, want := range []int{2, 2, 1, 0} {
+		n, err := sub.Poll(ctx)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got := n; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+
+	if got, want := handler.Len(), 5; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := sub.Offset(), uint64(5); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	offset, err := checkpoints.LoadCheckpoint(ctx, "test")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := offset, uint64(5); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSubscription_ResumeFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	serializer := eventsource.NewJSONSerializer(ItemAdded{})
+	store := newStore(t, serializer, 5)
+	checkpoints := subscription.NewMemoryCheckpointStore()
+	if err := checkpoints.SaveCheckpoint(ctx, "test", 3); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	handler := &recorder{}
+
+	sub := subscription.New("test", store, handler,
+		subscription.WithSerializer(serializer),
+		subscription.WithCheckpointStore(checkpoints),
+	)
+	if _, err := sub.Poll(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if got, want := handler.versions, []int{4, 5}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSubscription_HandlerError(t *testing.T) {
+	ctx := context.Background()
+	serializer := eventsource.NewJSONSerializer(ItemAdded{})
+	store := newStore(t, serializer, 3)
+	checkpoints := subscription.NewMemoryCheckpointStore()
+
+	boom := errors.New("boom")
+	handler := subscription.HandlerFunc(func(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error {
+		if record.Offset == 2 {
+			return boom
+		}
+		return nil
+	})
+
+	sub := subscription.New("test", store, handler, subscription.WithCheckpointStore(checkpoints))
+	n, err := sub.Poll(ctx)
+	if !errors.Is(err, boom) {
+		t.Fatalf("got %v; want %v", err, boom)
+	}
+	if got, want := n, 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// progress prior to the failed record should be kept
+	offset, err := checkpoints.LoadCheckpoint(ctx, "test")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := offset, uint64(1); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSubscription_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serializer := eventsource.NewJSONSerializer(ItemAdded{})
+	store := newStore(t, serializer, 3)
+
+	var mux sync.Mutex
+	var failed bool
+	handler := &recorder{}
+	flaky := subscription.HandlerFunc(func(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error {
+		mux.Lock()
+		defer mux.Unlock()
+
+		if !failed {
+			failed = true
+			return errors.New("transient")
+		}
+		return handler.Handle(ctx, record, event)
+	})
+
+	sub := subscription.New("test", store, flaky,
+		subscription.WithSerializer(serializer),
+		subscription.WithPollInterval(time.Millisecond),
+		subscription.WithBackoff(time.Millisecond, 5*time.Millisecond),
+	)
+
+	done := make(chan error, 1)
+	go func() { done <- sub.Run(ctx) }()
+
+	// records saved while the subscription is running are picked up by the next poll
+	time.Sleep(10 * time.Millisecond)
+	record, err := serializer.MarshalEvent(ItemAdded{Model: eventsource.Model{ID: "abc", Version: 4}})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Save(ctx, "abc", record); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for handler.Len() < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for records; got %v", handler.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := sub.Offset(), uint64(4); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSubscription_Notify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serializer := eventsource.NewJSONSerializer(ItemAdded{})
+	store := newStore(t, serializer, 1)
+	handler := &recorder{}
+
+	// the poll interval is long enough that only a notification from the store can wake the
+	// subscription
+	sub := subscription.New("test", store, handler,
+		subscription.WithSerializer(serializer),
+		subscription.WithPollInterval(time.Hour),
+	)
+
+	done := make(chan error, 1)
+	go func() { done <- sub.Run(ctx) }()
+
+	for handler.Len() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	record, err := serializer.MarshalEvent(ItemAdded{Model: eventsource.Model{ID: "abc", Version: 2}})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Save(ctx, "abc", record); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for handler.Len() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for notification")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+}
+
+func TestSubscription_Cause(t *testing.T) {
+	ctx := context.Background()
+	store := eventsource.NewMemoryStore()
+	record := eventsource.Record{
+		Version:  1,
+		Data:     []byte("data"),
+		Metadata: map[string]string{eventsource.MetadataEventID: "1", eventsource.MetadataActor: "alice"},
+	}
+	if err := store.Save(ctx, "abc", record); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	var md map[string]string
+	handler := subscription.HandlerFunc(func(ctx context.Context, record eventsource.StreamRecord, event eventsource.Event) error {
+		md = eventsource.MetadataFromContext(ctx)
+		return nil
+	})
+
+	sub := subscription.New("test", store, handler)
+	if _, err := sub.Poll(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if got, want := md[eventsource.MetadataCausationID], "1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := md[eventsource.MetadataActor], "alice"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
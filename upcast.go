@@ -0,0 +1,79 @@
+package eventsource
+
+import (
+	"encoding/json"
+
+	"golang.org/x/xerrors"
+)
+
+// maxUpcastDepth limits the number of upcasters a single stored event may pass through; guards
+// against upcasters that convert events back and forth between types
+const maxUpcastDepth = 64
+
+// UpcastEvent contains a stored event as it passes through the upcaster chain
+type UpcastEvent struct {
+	// Type contains the event type as returned by EventType
+	Type string
+
+	// Revision contains the schema revision of Data
+	Revision int
+
+	// Data contains the json encoded event
+	Data json.RawMessage
+}
+
+// Upcaster converts an event from one schema revision to the next e.g. by renaming fields or
+// providing defaults.  An upcaster may also split an event by returning more than one event,
+// drop an event by returning none, or rename an event by returning a different Type
+type Upcaster func(event UpcastEvent) ([]UpcastEvent, error)
+
+type upcastKey struct {
+	eventType string
+	revision  int
+}
+
+// Upcast registers fn to convert events of the specified type from revision to revision+1.
+// The events returned by fn that share the same type are assigned revision+1; events of other
+// types keep the revision assigned by fn and continue through their own upcasters.
+//
+// The current revision of an event type, recorded by MarshalEvent, is one more than the highest
+// revision registered with Upcast.  Events stored before any upcasters were registered have
+// revision 0
+func (j *JSONSerializer) Upcast(eventType string, revision int, fn Upcaster) {
+	j.upcasters[upcastKey{eventType: eventType, revision: revision}] = fn
+	if next := revision + 1; next > j.revisions[eventType] {
+		j.revisions[eventType] = next
+	}
+}
+
+// upcast passes the event through the upcasters registered for its type and revision until
+// no further upcasters apply
+func (j *JSONSerializer) upcast(event UpcastEvent, depth int) ([]UpcastEvent, error) {
+	fn, ok := j.upcasters[upcastKey{eventType: event.Type, revision: event.Revision}]
+	if !ok {
+		return []UpcastEvent{event}, nil
+	}
+	if depth >= maxUpcastDepth {
+		return nil, xerrors.Errorf("unable to upcast event, %v, at revision %v: exceeded %v upcasts: %w", event.Type, event.Revision, maxUpcastDepth, errInvalidEncoding)
+	}
+
+	upcast, err := fn(event)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to upcast event, %v, from revision %v: %w", event.Type, event.Revision, err)
+	}
+
+	var events []UpcastEvent
+	for _, item := range upcast {
+		if item.Type == event.Type {
+			item.Revision = event.Revision + 1
+		}
+
+		v, err := j.upcast(item, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, v...)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,156 @@
+package eventsource_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+type UserRegistered struct {
+	eventsource.Model
+	FullName string
+	Country  string
+}
+
+type StreetChanged struct {
+	eventsource.Model
+	Street string
+}
+
+type CityChanged struct {
+	eventsource.Model
+	City string
+}
+
+// rewrite returns an upcaster that decodes the event data into a map and applies fn
+func rewrite(fn func(data map[string]interface{})) eventsource.Upcaster {
+	return func(event eventsource.UpcastEvent) ([]eventsource.UpcastEvent, error) {
+		var data map[string]interface{}
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return nil, err
+		}
+		fn(data)
+
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		event.Data = encoded
+		return []eventsource.UpcastEvent{event}, nil
+	}
+}
+
+func TestJSONSerializer_Upcast(t *testing.T) {
+	serializer := eventsource.NewJSONSerializer(UserRegistered{})
+
+	// revision 0 -> 1 renames Name to FullName
+	serializer.Upcast("UserRegistered", 0, rewrite(func(data map[string]interface{}) {
+		data["FullName"] = data["Name"]
+		delete(data, "Name")
+	}))
+
+	// revision 1 -> 2 adds Country with a default
+	serializer.Upcast("UserRegistered", 1, rewrite(func(data map[string]interface{}) {
+		data["Country"] = "NZ"
+	}))
+
+	// stored before either upcaster existed
+	record := eventsource.Record{
+		Version: 1,
+		Data:    []byte(`{"t":"UserRegistered","d":{"ID":"abc","Version":1,"Name":"alice"}}`),
+	}
+
+	v, err := serializer.UnmarshalEvent(record)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	event := v.(*UserRegistered)
+	if got, want := event.FullName, "alice"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := event.Country, "NZ"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// new records are written at the current revision and are not upcast again
+	event.Country = "AU"
+	record, err = serializer.MarshalEvent(event)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := string(record.Data), `"r":2`; !strings.Contains(got, want) {
+		t.Fatalf("got %v; want to contain %v", got, want)
+	}
+
+	v, err = serializer.UnmarshalEvent(record)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := v.(*UserRegistered).Country, "AU"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestJSONSerializer_UpcastSplit(t *testing.T) {
+	serializer := eventsource.NewJSONSerializer(StreetChanged{}, CityChanged{})
+	serializer.Upcast("AddressChanged", 0, func(event eventsource.UpcastEvent) ([]eventsource.UpcastEvent, error) {
+		var data struct {
+			eventsource.Model
+			Street string
+			City   string
+		}
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return nil, err
+		}
+
+		street, _ := json.Marshal(StreetChanged{Model: data.Model, Street: data.Street})
+		city, _ := json.Marshal(CityChanged{Model: data.Model, City: data.City})
+		return []eventsource.UpcastEvent{
+			{Type: "StreetChanged", Data: street},
+			{Type: "CityChanged", Data: city},
+		}, nil
+	})
+
+	record := eventsource.Record{
+		Version: 2,
+		Data:    []byte(`{"t":"AddressChanged","d":{"ID":"abc","Version":2,"Street":"Queen St","City":"Auckland"}}`),
+	}
+
+	events, err := eventsource.UnmarshalEvents(serializer, record)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(events), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := events[0].(*StreetChanged).Street, "Queen St"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := events[1].(*CityChanged).City, "Auckland"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// UnmarshalEvent cannot return more than one event
+	if _, err := serializer.UnmarshalEvent(record); err == nil {
+		t.Fatalf("got nil; want not nil")
+	}
+}
+
+func TestJSONSerializer_UpcastError(t *testing.T) {
+	boom := errors.New("boom")
+	serializer := eventsource.NewJSONSerializer(UserRegistered{})
+	serializer.Upcast("UserRegistered", 0, func(event eventsource.UpcastEvent) ([]eventsource.UpcastEvent, error) {
+		return nil, boom
+	})
+
+	_, err := serializer.UnmarshalEvent(eventsource.Record{
+		Version: 1,
+		Data:    []byte(`{"t":"UserRegistered","d":{}}`),
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("got %v; want %v", err, boom)
+	}
+}